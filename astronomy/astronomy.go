@@ -0,0 +1,46 @@
+// Package astronomy computes sun and moon events for a location without
+// contacting any service. The formulas are taken from "Astronomical
+// Algorithms" by Jean Meeus in the simplified form also used by the suncalc
+// library and are accurate to about a minute.
+package astronomy
+
+import (
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// Fill sets all zero sun and moon data of astro to the values computed for the
+// calendar day of date at loc. Values already provided by a backend are kept.
+func Fill(astro *iface.Astro, date time.Time, loc iface.LatLon) {
+	sun := Sun(date, float64(loc.Latitude), float64(loc.Longitude))
+	moon := Moon(date, float64(loc.Latitude), float64(loc.Longitude))
+	fill := func(dst *time.Time, src time.Time) {
+		if dst.IsZero() {
+			*dst = src
+		}
+	}
+	fill(&astro.Sunrise, sun.Sunrise)
+	fill(&astro.Sunset, sun.Sunset)
+	fill(&astro.SolarNoon, sun.SolarNoon)
+	fill(&astro.CivilDawn, sun.CivilDawn)
+	fill(&astro.CivilDusk, sun.CivilDusk)
+	fill(&astro.NauticalDawn, sun.NauticalDawn)
+	fill(&astro.NauticalDusk, sun.NauticalDusk)
+	fill(&astro.AstronomicalDawn, sun.AstronomicalDawn)
+	fill(&astro.AstronomicalDusk, sun.AstronomicalDusk)
+	fill(&astro.Moonrise, moon.Moonrise)
+	fill(&astro.Moonset, moon.Moonset)
+	if astro.MoonPhase == iface.MoonPhaseUnknown {
+		astro.MoonPhase = moon.Phase
+		astro.MoonAge = moon.Age
+		astro.MoonIllumination = moon.Illumination
+	}
+	if astro.DayLength == 0 {
+		if !astro.Sunrise.IsZero() && !astro.Sunset.IsZero() {
+			astro.DayLength = astro.Sunset.Sub(astro.Sunrise)
+		} else {
+			astro.DayLength = sun.DayLength
+		}
+	}
+}
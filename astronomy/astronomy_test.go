@@ -0,0 +1,140 @@
+package astronomy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// tolerance is the allowed difference to the expected times. The formulas are
+// accurate to about a minute and the expected times are rounded to minutes.
+const tolerance = 3 * time.Minute
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+	return loc
+}
+
+// clock returns the time hh:mm on the date of day.
+func clock(day time.Time, hh, mm int) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d, hh, mm, 0, 0, day.Location())
+}
+
+func near(got, want time.Time) bool {
+	diff := got.Sub(want)
+	return diff > -tolerance && diff < tolerance
+}
+
+func TestSun(t *testing.T) {
+	tests := []struct {
+		name      string
+		zone      string
+		date      [3]int
+		lat, lon  float64
+		rise, set [2]int
+		dayLength time.Duration
+		noRise    bool
+	}{
+		{"berlin summer solstice", "Europe/Berlin", [3]int{2026, 6, 21}, 52.52, 13.405, [2]int{4, 43}, [2]int{21, 33}, 16*time.Hour + 50*time.Minute, false},
+		{"new york winter solstice", "America/New_York", [3]int{2026, 12, 21}, 40.7128, -74.006, [2]int{7, 16}, [2]int{16, 32}, 9*time.Hour + 16*time.Minute, false},
+		{"sydney southern summer", "Australia/Sydney", [3]int{2026, 1, 1}, -33.8688, 151.2093, [2]int{5, 47}, [2]int{20, 9}, 14*time.Hour + 22*time.Minute, false},
+		{"tromsø polar night", "Europe/Oslo", [3]int{2026, 12, 21}, 69.6492, 18.9553, [2]int{}, [2]int{}, 0, true},
+		{"tromsø polar day", "Europe/Oslo", [3]int{2026, 6, 21}, 69.6492, 18.9553, [2]int{}, [2]int{}, 24 * time.Hour, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			day := time.Date(test.date[0], time.Month(test.date[1]), test.date[2], 0, 0, 0, 0, mustLoad(t, test.zone))
+			got := Sun(day, test.lat, test.lon)
+
+			if test.noRise {
+				if !got.Sunrise.IsZero() || !got.Sunset.IsZero() {
+					t.Errorf("sunrise %v, sunset %v, want none", got.Sunrise, got.Sunset)
+				}
+			} else {
+				if want := clock(day, test.rise[0], test.rise[1]); !near(got.Sunrise, want) {
+					t.Errorf("sunrise %v, want %v", got.Sunrise, want)
+				}
+				if want := clock(day, test.set[0], test.set[1]); !near(got.Sunset, want) {
+					t.Errorf("sunset %v, want %v", got.Sunset, want)
+				}
+				if got.Sunrise.Location() != day.Location() {
+					t.Errorf("sunrise in %v, want %v", got.Sunrise.Location(), day.Location())
+				}
+				if !(got.CivilDawn.Before(got.Sunrise) && got.Sunset.Before(got.CivilDusk)) {
+					t.Errorf("civil twilight %v–%v does not enclose the day", got.CivilDawn, got.CivilDusk)
+				}
+			}
+			if d := got.DayLength - test.dayLength; d <= -tolerance || d >= tolerance {
+				t.Errorf("day length %v, want %v", got.DayLength, test.dayLength)
+			}
+			if !sameDay(got.SolarNoon, day) {
+				t.Errorf("solar noon %v is not on %v", got.SolarNoon, day)
+			}
+		})
+	}
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+func TestMoon(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	tests := []struct {
+		name               string
+		date               time.Time
+		phase              iface.MoonPhase
+		minIllum, maxIllum float32
+		rise               [2]int
+	}{
+		// The total solar eclipse of 12 August 2026 happens at new moon.
+		{"new moon", time.Date(2026, 8, 12, 0, 0, 0, 0, berlin), iface.MoonNew, 0, 2, [2]int{4, 57}},
+		{"first quarter", time.Date(2026, 8, 20, 0, 0, 0, 0, berlin), iface.MoonFirstQuarter, 45, 60, [2]int{15, 41}},
+		// The partial lunar eclipse of 28 August 2026 happens at full moon.
+		{"full moon", time.Date(2026, 8, 28, 0, 0, 0, 0, berlin), iface.MoonFull, 98, 100, [2]int{19, 57}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Moon(test.date, 52.52, 13.405)
+			if got.Phase != test.phase {
+				t.Errorf("phase %v, want %v", got.Phase, test.phase)
+			}
+			if got.Illumination < test.minIllum || got.Illumination > test.maxIllum {
+				t.Errorf("illumination %v%%, want %v–%v%%", got.Illumination, test.minIllum, test.maxIllum)
+			}
+			if want := clock(test.date, test.rise[0], test.rise[1]); !near(got.Moonrise, want) {
+				t.Errorf("moonrise %v, want %v", got.Moonrise, want)
+			}
+			if got.Age < 0 || got.Age > time.Duration(synodicMonth*dayNanos) {
+				t.Errorf("age %v out of range", got.Age)
+			}
+		})
+	}
+}
+
+func TestFillKeepsBackendValues(t *testing.T) {
+	day := time.Date(2026, 6, 21, 0, 0, 0, 0, time.UTC)
+	sunrise := time.Date(2026, 6, 21, 2, 0, 0, 0, time.UTC)
+	astro := iface.Astro{Sunrise: sunrise}
+	Fill(&astro, day, iface.LatLon{Latitude: 52.52, Longitude: 13.405})
+
+	if !astro.Sunrise.Equal(sunrise) {
+		t.Errorf("sunrise overwritten with %v", astro.Sunrise)
+	}
+	if astro.Sunset.IsZero() || astro.MoonPhase == iface.MoonPhaseUnknown {
+		t.Errorf("sunset %v, moon phase %v not filled", astro.Sunset, astro.MoonPhase)
+	}
+	if want := astro.Sunset.Sub(sunrise); astro.DayLength != want {
+		t.Errorf("day length %v, want %v from the kept sunrise", astro.DayLength, want)
+	}
+}
@@ -0,0 +1,149 @@
+package astronomy
+
+import (
+	"math"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+const (
+	// synodicMonth is the mean time between two new moons in days.
+	synodicMonth = 29.530588853
+
+	// sunDistance is the mean distance between earth and sun in km.
+	sunDistance = 149598000
+)
+
+// MoonTimes are the moon events of a single day. Moonrise or moonset are zero
+// if they do not happen on that day.
+type MoonTimes struct {
+	Moonrise time.Time
+	Moonset  time.Time
+
+	Phase iface.MoonPhase
+
+	// Age is the time since the last new moon.
+	Age time.Duration
+
+	// Illumination is the illuminated fraction of the moon in percent.
+	Illumination float32
+}
+
+// moonCoords returns right ascension, declination and distance in km of the
+// moon d days after J2000.
+func moonCoords(d float64) (ra, dec, dist float64) {
+	l := rad * (218.316 + 13.176396*d)
+	m := rad * (134.963 + 13.064993*d)
+	f := rad * (93.272 + 13.229350*d)
+
+	lng := l + rad*6.289*math.Sin(m)
+	lat := rad * 5.128 * math.Sin(f)
+	return rightAscension(lng, lat), declination(lng, lat), 385001 - 20905*math.Cos(m)
+}
+
+// sunCoords returns right ascension and declination of the sun d days after
+// J2000.
+func sunCoords(d float64) (ra, dec float64) {
+	l := eclipticLongitude(solarMeanAnomaly(d))
+	return rightAscension(l, 0), declination(l, 0)
+}
+
+func siderealTime(d, lw float64) float64 {
+	return rad*(280.16+360.9856235*d) - lw
+}
+
+// moonAltitude returns the altitude of the moon in radians at t, corrected
+// for atmospheric refraction.
+func moonAltitude(t time.Time, phi, lw float64) float64 {
+	d := toDays(t)
+	ra, dec, _ := moonCoords(d)
+	h := math.Asin(math.Sin(phi)*math.Sin(dec) + math.Cos(phi)*math.Cos(dec)*math.Cos(siderealTime(d, lw)-ra))
+	return h + 0.0002967/math.Tan(math.Max(h, 0)+0.00312536/(math.Max(h, 0)+0.08901179))
+}
+
+// moonPhase returns the phase of the moon at t as a fraction of the synodic
+// month (0 is new moon, 0.5 full moon) and the illuminated fraction.
+func moonPhase(t time.Time) (phase, fraction float64) {
+	d := toDays(t)
+	sra, sdec := sunCoords(d)
+	mra, mdec, mdist := moonCoords(d)
+
+	phi := math.Acos(math.Sin(sdec)*math.Sin(mdec) + math.Cos(sdec)*math.Cos(mdec)*math.Cos(sra-mra))
+	inc := math.Atan2(sunDistance*math.Sin(phi), mdist-sunDistance*math.Cos(phi))
+	angle := math.Atan2(math.Cos(sdec)*math.Sin(sra-mra), math.Sin(sdec)*math.Cos(mdec)-math.Cos(sdec)*math.Sin(mdec)*math.Cos(sra-mra))
+
+	phase = 0.5 + 0.5*inc/math.Pi
+	if angle < 0 {
+		phase = 0.5 - 0.5*inc/math.Pi
+	}
+	return phase, (1 + math.Cos(inc)) / 2
+}
+
+// Moon computes the moon events of the calendar day of date at the location
+// lat, lon given in degrees. Phase, age and illumination are computed for noon.
+// The returned times are in the time zone of date.
+func Moon(date time.Time, lat, lon float64) (ret MoonTimes) {
+	loc := date.Location()
+	y, m, d := date.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, loc)
+
+	phase, fraction := moonPhase(midnight.Add(12 * time.Hour))
+	ret.Phase = iface.MoonPhase(int(phase*8+0.5)%8) + iface.MoonNew
+	ret.Age = time.Duration(phase * synodicMonth * dayNanos)
+	ret.Illumination = float32(fraction * 100)
+
+	// Scan the day in steps of two hours and find the times the moon crosses
+	// the horizon by fitting a parabola through three altitudes each.
+	phi := rad * lat
+	lw := rad * -lon
+	hc := 0.133 * rad
+	at := func(h float64) time.Time {
+		return midnight.Add(time.Duration(h * float64(time.Hour)))
+	}
+	var rise, set float64
+	h0 := moonAltitude(midnight, phi, lw) - hc
+	for i := 1.0; i <= 24 && (rise == 0 || set == 0); i += 2 {
+		h1 := moonAltitude(at(i), phi, lw) - hc
+		h2 := moonAltitude(at(i+1), phi, lw) - hc
+
+		a := (h0+h2)/2 - h1
+		b := (h2 - h0) / 2
+		xe := -b / (2 * a)
+		ye := (a*xe+b)*xe + h1
+		disc := b*b - 4*a*h1
+		if disc >= 0 {
+			dx := math.Sqrt(disc) / (math.Abs(a) * 2)
+			x1, x2 := xe-dx, xe+dx
+			roots := 0
+			if math.Abs(x1) <= 1 {
+				roots++
+			}
+			if math.Abs(x2) <= 1 {
+				roots++
+			}
+			if x1 < -1 {
+				x1 = x2
+			}
+
+			switch {
+			case roots == 1 && h0 < 0 && rise == 0:
+				rise = i + x1
+			case roots == 1 && h0 >= 0 && set == 0:
+				set = i + x1
+			case roots == 2 && ye < 0:
+				rise, set = i+x2, i+x1
+			case roots == 2:
+				rise, set = i+x1, i+x2
+			}
+		}
+		h0 = h2
+	}
+	if rise != 0 {
+		ret.Moonrise = at(rise)
+	}
+	if set != 0 {
+		ret.Moonset = at(set)
+	}
+	return ret
+}
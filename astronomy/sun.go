@@ -0,0 +1,135 @@
+package astronomy
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	rad      = math.Pi / 180
+	dayNanos = float64(24 * time.Hour)
+	j1970    = 2440588.0
+	j2000    = 2451545.0
+	j0       = 0.0009
+
+	// obliquity of the earth
+	obliquity = rad * 23.4397
+)
+
+// Sun altitudes of the different sun events in degrees.
+const (
+	altitudeSunrise      = -0.833
+	altitudeCivil        = -6
+	altitudeNautical     = -12
+	altitudeAstronomical = -18
+)
+
+// SunTimes are the sun events of a single day. Events which do not happen on
+// that day, e.g. the sunrise during polar night, are zero.
+type SunTimes struct {
+	Sunrise          time.Time
+	Sunset           time.Time
+	SolarNoon        time.Time
+	CivilDawn        time.Time
+	CivilDusk        time.Time
+	NauticalDawn     time.Time
+	NauticalDusk     time.Time
+	AstronomicalDawn time.Time
+	AstronomicalDusk time.Time
+
+	// DayLength is the time between sunrise and sunset. It is 24 hours during
+	// polar day and 0 during polar night.
+	DayLength time.Duration
+}
+
+func toJulian(t time.Time) float64 {
+	return float64(t.UnixNano())/dayNanos - 0.5 + j1970
+}
+
+func fromJulian(j float64, loc *time.Location) time.Time {
+	return time.Unix(0, int64((j+0.5-j1970)*dayNanos)).In(loc)
+}
+
+func toDays(t time.Time) float64 {
+	return toJulian(t) - j2000
+}
+
+func declination(l, b float64) float64 {
+	return math.Asin(math.Sin(b)*math.Cos(obliquity) + math.Cos(b)*math.Sin(obliquity)*math.Sin(l))
+}
+
+func rightAscension(l, b float64) float64 {
+	return math.Atan2(math.Sin(l)*math.Cos(obliquity)-math.Tan(b)*math.Sin(obliquity), math.Cos(l))
+}
+
+func solarMeanAnomaly(d float64) float64 {
+	return rad * (357.5291 + 0.98560028*d)
+}
+
+func eclipticLongitude(m float64) float64 {
+	center := rad * (1.9148*math.Sin(m) + 0.02*math.Sin(2*m) + 0.0003*math.Sin(3*m))
+	perihelion := rad * 102.9372
+	return m + center + perihelion + math.Pi
+}
+
+func julianCycle(d, lw float64) float64 {
+	return math.Round(d - j0 - lw/(2*math.Pi))
+}
+
+func approxTransit(ht, lw, n float64) float64 {
+	return j0 + (ht+lw)/(2*math.Pi) + n
+}
+
+func solarTransitJ(ds, m, l float64) float64 {
+	return j2000 + ds + 0.0053*math.Sin(m) - 0.0069*math.Sin(2*l)
+}
+
+// hourAngleCos returns the cosine of the hour angle at which the sun reaches
+// altitude h. Values outside of [-1, 1] mean the sun never reaches h.
+func hourAngleCos(h, phi, dec float64) float64 {
+	return (math.Sin(h) - math.Sin(phi)*math.Sin(dec)) / (math.Cos(phi) * math.Cos(dec))
+}
+
+// localNoon returns the approximate solar noon of the calendar day of date at
+// longitude lon.
+func localNoon(date time.Time, lon float64) time.Time {
+	y, m, d := date.Date()
+	return time.Date(y, m, d, 12, 0, 0, 0, time.UTC).Add(-time.Duration(lon / 15 * float64(time.Hour)))
+}
+
+// Sun computes the sun events of the calendar day of date at the location
+// lat, lon given in degrees. The returned times are in the time zone of date.
+func Sun(date time.Time, lat, lon float64) (ret SunTimes) {
+	loc := date.Location()
+	lw := rad * -lon
+	phi := rad * lat
+
+	d := toDays(localNoon(date, lon))
+	n := julianCycle(d, lw)
+	ds := approxTransit(0, lw, n)
+	m := solarMeanAnomaly(ds)
+	l := eclipticLongitude(m)
+	dec := declination(l, 0)
+	jnoon := solarTransitJ(ds, m, l)
+	ret.SolarNoon = fromJulian(jnoon, loc)
+
+	events := func(h float64) (rise, set time.Time) {
+		c := hourAngleCos(rad*h, phi, dec)
+		if c < -1 || c > 1 {
+			return
+		}
+		jset := solarTransitJ(approxTransit(math.Acos(c), lw, n), m, l)
+		return fromJulian(jnoon-(jset-jnoon), loc), fromJulian(jset, loc)
+	}
+	ret.Sunrise, ret.Sunset = events(altitudeSunrise)
+	ret.CivilDawn, ret.CivilDusk = events(altitudeCivil)
+	ret.NauticalDawn, ret.NauticalDusk = events(altitudeNautical)
+	ret.AstronomicalDawn, ret.AstronomicalDusk = events(altitudeAstronomical)
+
+	if !ret.Sunrise.IsZero() {
+		ret.DayLength = ret.Sunset.Sub(ret.Sunrise)
+	} else if hourAngleCos(rad*altitudeSunrise, phi, dec) < -1 {
+		ret.DayLength = 24 * time.Hour
+	}
+	return ret
+}
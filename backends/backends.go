@@ -0,0 +1,33 @@
+package backends
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// now returns the current time. Tests replace it to get reproducible results.
+var now = time.Now
+
+// rebase replaces the scheme and host of uri with the ones of base and
+// prefixes the path of uri with the path of base. It is used to send the
+// requests of a backend to a mirror, proxy or test server. uri is returned
+// unchanged if base is empty.
+func rebase(uri, base string) (string, error) {
+	if base == "" {
+		return uri, nil
+	}
+	b, err := url.Parse(base)
+	if err != nil || b.Scheme == "" || b.Host == "" {
+		return "", fmt.Errorf("invalid base url %q", base)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	u.Scheme, u.Host = b.Scheme, b.Host
+	u.Path = strings.TrimSuffix(b.Path, "/") + u.Path
+	u.RawPath = ""
+	return u.String(), nil
+}
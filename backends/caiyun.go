@@ -1,34 +1,62 @@
 package backends
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/schachmat/wego/httpclient"
 	"github.com/schachmat/wego/iface"
 )
 
 const (
-	CAIYUNAPI       = "http://api.caiyunapp.com/v2.6/%s/%s/weather?lang=%s&dailysteps=%s&hourlysteps=%s&alert=true&unit=metric:v2&begin=%s&granu=%s"
+	CAIYUNAPI       = "https://api.caiyunapp.com/v2.6/%s/%s/weather?lang=%s&dailysteps=%s&hourlysteps=%s&alert=true&unit=metric:v2&begin=%s&granu=%s"
 	CAIYUNDATE_TMPL = "2006-01-02T15:04-07:00"
 )
 
 type CaiyunConfig struct {
-	apiKey string
-	lang   string
-	debug  bool
+	apiKey  string
+	lang    string
+	debug   bool
+	baseURL string
+}
+
+// CaiyunOptions configures the caiyunapp.com backend when it is used as a
+// library.
+type CaiyunOptions struct {
+	// APIKey is the caiyunapp.com api key to use.
+	APIKey string
+
+	// Lang is the language of the weather descriptions. Defaults to "en".
+	Lang string
+
+	// Debug prints raw requests and responses.
+	Debug bool
+
+	// BaseURL replaces the scheme and host of the api, e.g. to use a mirror or
+	// a test server.
+	BaseURL string
+}
+
+// New returns a caiyunapp.com backend configured by o.
+func (o CaiyunOptions) New() iface.Backend {
+	c := &CaiyunConfig{apiKey: o.APIKey, lang: o.Lang, debug: o.Debug, baseURL: o.BaseURL}
+	if c.lang == "" {
+		c.lang = "en"
+	}
+	return c
 }
 
 func (c *CaiyunConfig) Setup() {
 	flag.StringVar(&c.apiKey, "caiyun-api-key", "", "caiyun backend: the api `KEY` to use")
 	flag.StringVar(&c.lang, "caiyun-lang", "en", "caiyun backend: the `LANGUAGE` to request from caiyunapp.com/")
 	flag.BoolVar(&c.debug, "caiyun-debug", true, "caiyun backend: print raw requests and responses")
+	flag.StringVar(&c.baseURL, "caiyun-base-url", "", "caiyun backend: the `URL` of a mirror or test server to use instead of api.caiyunapp.com")
 }
 
 var SkyconToIfaceCode map[string]iface.WeatherCode
@@ -58,29 +86,67 @@ func init() {
 	}
 }
 
-func ParseCoordinates(latlng string) (float64, float64, error) {
-	s := strings.Split(latlng, ",")
-	if len(s) != 2 {
-		return 0, 0, fmt.Errorf("input %v split to %v parts", latlng, len(s))
+// skyconPrecipType returns the kind of precipitation of a caiyun skycon.
+func skyconPrecipType(skycon string) iface.PrecipType {
+	if _, ok := SkyconToIfaceCode[skycon]; !ok {
+		return iface.PrecipUnknown
+	} else if strings.HasSuffix(skycon, "_RAIN") {
+		return iface.PrecipRain
+	} else if strings.HasSuffix(skycon, "_SNOW") {
+		return iface.PrecipSnow
 	}
+	return iface.PrecipNone
+}
 
-	lat, err := strconv.ParseFloat(s[0], 64)
-	if err != nil {
-		return 0, 0, fmt.Errorf("parse Coodinates failed input %v get parts %v", latlng, s[0])
+// caiyunParseAlerts converts the alerts of a caiyun response. The last two
+// digits of an alert code are the warning level from blue (01) to red (04).
+func caiyunParseAlerts(weatherData *CaiyunWeather) (ret []iface.Alert) {
+	levels := map[string]iface.AlertSeverity{
+		"01": iface.SeverityMinor,
+		"02": iface.SeverityModerate,
+		"03": iface.SeveritySevere,
+		"04": iface.SeverityExtreme,
 	}
+	for _, content := range weatherData.Result.Alert.Content {
+		alert := iface.Alert{
+			Title:       content.Title,
+			Description: content.Description,
+			Source:      content.Source,
+		}
+		if len(content.Code) == 4 {
+			alert.Severity = levels[content.Code[2:]]
+		}
+		if content.Pubtimestamp > 0 {
+			alert.Onset = time.Unix(int64(content.Pubtimestamp), 0)
+		}
+		ret = append(ret, alert)
+	}
+	return ret
+}
 
-	lng, err := strconv.ParseFloat(s[1], 64)
-	if err != nil {
-		return 0, 0, fmt.Errorf("parse Coodinates failed input %v get parts %v", latlng, s[1])
+// caiyunParseNowcast converts the minutely precipitation forecast of the next
+// two hours starting at now. The probabilities are given for half hour blocks.
+func caiyunParseNowcast(weatherData *CaiyunWeather, now time.Time) (ret []iface.Cond) {
+	minutely := weatherData.Result.Minutely
+	for i, intensity := range minutely.Precipitation2H {
+		slot := iface.Cond{Time: now.Add(time.Duration(i) * time.Minute)}
+		precip := float32(intensity) / 1000
+		slot.PrecipM = &precip
+		if i/30 < len(minutely.Probability) {
+			chance := int(minutely.Probability[i/30] * 100)
+			slot.ChanceOfRainPercent = &chance
+		}
+		ret = append(ret, slot)
 	}
-	return lat, lng, nil
+	return ret
 }
 
-func (c *CaiyunConfig) GetWeatherDataFromLocalBegin(lng float64, lat float64, numdays int) (*CaiyunWeather, error) {
-	cyLocation := fmt.Sprintf("%v,%v", lng, lat)
+func (c *CaiyunConfig) GetWeatherDataFromLocalBegin(ctx context.Context, lng float64, lat float64, numdays int) (*CaiyunWeather, error) {
+	// the coordinates were float32, print them as short as those
+	cyLocation := strconv.FormatFloat(lng, 'f', -1, 32) + "," + strconv.FormatFloat(lat, 'f', -1, 32)
 
 	localBegin, err := func() (*time.Time, error) {
-		now := time.Now()
+		now := now()
 		url := fmt.Sprintf(
 			CAIYUNAPI, c.apiKey, cyLocation, c.lang,
 			strconv.FormatInt(int64(numdays), 10), strconv.FormatInt(int64(numdays)*24, 10),
@@ -88,26 +154,14 @@ func (c *CaiyunConfig) GetWeatherDataFromLocalBegin(lng float64, lat float64, nu
 			"realtime",
 		)
 		url += "fields=temperature"
-		resp, err := http.Get(url)
+		weatherData, err := c.request(ctx, url, "phase 1")
 		if err != nil {
 			return nil, err
 		}
-		defer resp.Body.Close()
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		if c.debug {
-			log.Printf("caiyun request phase 1 %v \n%v\n", url, string(body))
-		}
-		weatherData := &CaiyunWeather{}
-		if err := json.Unmarshal(body, weatherData); err != nil {
-			return nil, err
-		}
 
 		loc, err := time.LoadLocation(weatherData.Timezone)
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("%w: %v", iface.ErrParse, err)
 		}
 		localNow := now.In(loc)
 		localBegin := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, loc)
@@ -123,38 +177,69 @@ func (c *CaiyunConfig) GetWeatherDataFromLocalBegin(lng float64, lat float64, nu
 		strconv.FormatInt(localBegin.Unix(), 10),
 		"realtime,minutely,hourly,daily",
 	)
-	resp, err := http.Get(url)
+	return c.request(ctx, url, "phase 2")
+}
+
+// request fetches and decodes a single api response. The phase is only used in
+// debug output.
+func (c *CaiyunConfig) request(ctx context.Context, url string, phase string) (*CaiyunWeather, error) {
+	url, err := rebase(url, c.baseURL)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	body, err := httpclient.Get(ctx, url)
+	if c.debug && body != nil {
+		log.Printf("caiyun request %s %v \n%v\n", phase, url, string(body))
+	}
 	if err != nil {
 		return nil, err
 	}
-	if c.debug {
-		log.Printf("caiyun request phase 2 %v \n%v\n", url, string(body))
-	}
 	weatherData := &CaiyunWeather{}
 	if err := json.Unmarshal(body, weatherData); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", iface.ErrParse, err)
+	}
+	if weatherData.Status == "failed" {
+		return nil, fmt.Errorf("%w: %s", iface.ErrUpstream, weatherData.Error)
 	}
 	return weatherData, nil
 }
 
-func (c *CaiyunConfig) Fetch(location string, numdays int) iface.Data {
+// caiyunParseClock returns the time of day given as "15:04" on the date of
+// day.
+func caiyunParseClock(day time.Time, clock string) (time.Time, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", iface.ErrParse, err)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location()), nil
+}
+
+// Language returns the language the weather descriptions are requested in.
+func (c *CaiyunConfig) Language() string {
+	return c.lang
+}
+
+func (c *CaiyunConfig) SupportedLocations() iface.LocationKind {
+	return iface.LocationCoords
+}
+
+func (c *CaiyunConfig) Fetch(ctx context.Context, location iface.Location, numdays int) (iface.Data, error) {
 	if c.debug {
 		log.Printf("caiyun location %v", location)
 	}
 	res := iface.Data{}
-	lat, lng, err := ParseCoordinates(location)
+	if err := location.Check("caiyun", c.SupportedLocations()); err != nil {
+		return res, err
+	}
+	weatherData, err := c.GetWeatherDataFromLocalBegin(ctx, float64(location.LatLon.Longitude), float64(location.LatLon.Latitude), numdays)
 	if err != nil {
-		panic(err)
+		return res, err
 	}
-	weatherData, err := c.GetWeatherDataFromLocalBegin(lng, lat, numdays)
+	loc, err := time.LoadLocation(weatherData.Timezone)
 	if err != nil {
-		panic(err)
+		return res, fmt.Errorf("%w: %v", iface.ErrParse, err)
 	}
+	res.TimeZone = iface.TimeZone{Location: loc}
 	res.Current.Desc = weatherData.Result.Minutely.Description + "\t" + weatherData.Result.Hourly.Description
 
 	res.Current.TempC = func() *float32 {
@@ -173,8 +258,6 @@ func (c *CaiyunConfig) Fetch(location string, numdays int) iface.Data {
 		if len(adcodes) == 2 {
 			res.Location = adcodes[0].Name + adcodes[1].Name
 		}
-	} else {
-		res.Location = "第三红岸基地"
 	}
 	res.Current.WinddirDegree = func() *int {
 		x := int(weatherData.Result.Realtime.Wind.Direction)
@@ -196,132 +279,172 @@ func (c *CaiyunConfig) Fetch(location string, numdays int) iface.Data {
 		x := int(weatherData.Result.Realtime.Humidity * 100)
 		return &x
 	}()
-	res.Current.ChanceOfRainPercent = func() *int {
-		x := int(weatherData.Result.Minutely.Probability[0] * 100)
-		return &x
-	}()
+	if probability := weatherData.Result.Minutely.Probability; len(probability) > 0 {
+		x := int(probability[0] * 100)
+		res.Current.ChanceOfRainPercent = &x
+	}
 	res.Current.VisibleDistM = func() *float32 {
 		x := float32(weatherData.Result.Realtime.Visibility)
 		return &x
 	}()
-	res.Current.Time = func() time.Time {
-		loc, err := time.LoadLocation(weatherData.Timezone)
-		if err != nil {
-			panic(err)
-		}
-		return time.Now().In(loc)
+	res.Current.PressureHPa = func() *float32 {
+		x := float32(weatherData.Result.Realtime.Pressure) / 100 // Pa to hPa
+		return &x
 	}()
-	dailyDataSlice := []iface.Day{}
-	for i := 0; i < numdays; i++ {
-		weatherDailyData := weatherData.Result.Daily
+	res.Current.CloudCoverPercent = func() *int {
+		x := int(weatherData.Result.Realtime.Cloudrate * 100)
+		return &x
+	}()
+	res.Current.UVIndex = func() *float32 {
+		x := float32(weatherData.Result.Realtime.LifeIndex.Ultraviolet.Index)
+		return &x
+	}()
+	res.Current.PrecipType = skyconPrecipType(weatherData.Result.Realtime.Skycon)
+	res.Alerts = caiyunParseAlerts(weatherData)
+	res.Current.AirQuality = func() *iface.AirQuality {
+		aq := weatherData.Result.Realtime.AirQuality
+		usa, chn := aq.Aqi.Usa, aq.Aqi.Chn
+		pm25, pm10 := float32(aq.Pm25), float32(aq.Pm10)
+		o3, no2 := float32(aq.O3), float32(aq.No2)
+		return &iface.AirQuality{AQIUS: &usa, AQIChina: &chn, PM25: &pm25, PM10: &pm10, O3: &o3, NO2: &no2}
+	}()
+	res.Current.Time = now().In(loc)
+	res.Nowcast = caiyunParseNowcast(weatherData, time.Unix(int64(weatherData.ServerTime), 0).In(loc).Truncate(time.Minute))
+	weatherDailyData := weatherData.Result.Daily
+	if len(weatherDailyData.Temperature) < numdays || len(weatherDailyData.Astro) < numdays {
+		return res, fmt.Errorf("%w: expected %d days of forecast, got %d", iface.ErrParse, numdays, len(weatherDailyData.Temperature))
+	}
 
-		dailyData := iface.Day{
-			Date: func() time.Time {
-				x, err := time.Parse(CAIYUNDATE_TMPL, weatherDailyData.Temperature[i].Date)
-				if err != nil {
-					panic(err)
-				}
-				return x
-			}(),
-			Slots: []iface.Cond{},
+	weatherHourlyData := weatherData.Result.Hourly
+	if n := len(weatherHourlyData.Temperature); len(weatherHourlyData.Visibility) < n || len(weatherHourlyData.Humidity) < n ||
+		len(weatherHourlyData.Wind) < n || len(weatherHourlyData.Precipitation) < n || len(weatherHourlyData.ApparentTemperature) < n {
+		return res, fmt.Errorf("%w: hourly forecast incomplete", iface.ErrParse)
+	}
+	var slots []iface.Cond
+	for index, houryTmp := range weatherHourlyData.Temperature {
+		slotTime, err := time.Parse(CAIYUNDATE_TMPL, houryTmp.Datetime)
+		if err != nil {
+			return res, fmt.Errorf("%w: %v", iface.ErrParse, err)
 		}
-
-		dailyData.Astronomy = iface.Astro{
-			Sunrise: func() time.Time {
-				s := strings.Split(weatherDailyData.Astro[i].Sunset.Time, ":")
-				hourStr := s[0]
-				minuteStr := s[1]
-				hour, err := strconv.Atoi(hourStr)
-				if err != nil {
-					panic(err)
+		skycon := ""
+		if index < len(weatherHourlyData.Skycon) {
+			skycon = weatherHourlyData.Skycon[index].Value
+		}
+		slots = append(slots, iface.Cond{
+			TempC: func() *float32 {
+				x := float32(weatherData.Result.Hourly.Temperature[index].Value)
+				return &x
+			}(),
+			VisibleDistM: func() *float32 {
+				x := float32(weatherHourlyData.Visibility[index].Value)
+				return &x
+			}(),
+			Humidity: func() *int {
+				x := int(weatherHourlyData.Humidity[index].Value)
+				return &x
+			}(),
+			WindspeedKmph: func() *float32 {
+				x := float32(weatherHourlyData.Wind[index].Speed)
+				return &x
+			}(),
+			WinddirDegree: func() *int {
+				x := int(weatherHourlyData.Wind[index].Direction)
+				return &x
+			}(),
+			Time: slotTime,
+			Code: func() iface.WeatherCode {
+				if code, ok := SkyconToIfaceCode[skycon]; ok {
+					return code
+				} else {
+					return iface.CodeUnknown
 				}
-				minute, err := strconv.Atoi(minuteStr)
-				if err != nil {
-					panic(err)
+			}(),
+			PrecipM: func() *float32 {
+				x := float32(weatherHourlyData.Precipitation[index].Value) / 1000
+				return &x
+			}(),
+			FeelsLikeC: func() *float32 {
+				x := float32(weatherData.Result.Hourly.ApparentTemperature[index].Value)
+				return &x
+			}(),
+			PressureHPa: func() *float32 {
+				if index >= len(weatherHourlyData.Pressure) {
+					return nil
 				}
-				x := time.Date(dailyData.Date.Year(), dailyData.Date.Month(), dailyData.Date.Day(), hour, minute, 0, 0, dailyData.Date.Location())
-				return x
+				x := float32(weatherHourlyData.Pressure[index].Value) / 100 // Pa to hPa
+				return &x
 			}(),
-			Sunset: func() time.Time {
-				s := strings.Split(weatherDailyData.Astro[i].Sunset.Time, ":")
-				hourStr := s[0]
-				minuteStr := s[1]
-				hour, err := strconv.Atoi(hourStr)
-				if err != nil {
-					panic(err)
+			CloudCoverPercent: func() *int {
+				if index >= len(weatherHourlyData.Cloudrate) {
+					return nil
 				}
-				minute, err := strconv.Atoi(minuteStr)
-				if err != nil {
-					panic(err)
+				x := int(weatherHourlyData.Cloudrate[index].Value * 100)
+				return &x
+			}(),
+			PrecipType: skyconPrecipType(skycon),
+			AirQuality: func() *iface.AirQuality {
+				aqi, pm25 := weatherHourlyData.AirQuality.Aqi, weatherHourlyData.AirQuality.Pm25
+				if index >= len(aqi) || index >= len(pm25) {
+					return nil
 				}
-				x := time.Date(dailyData.Date.Year(), dailyData.Date.Month(), dailyData.Date.Day(), hour, minute, 0, 0, dailyData.Date.Location())
-				return x
+				usa, chn := aqi[index].Value.Usa, aqi[index].Value.Chn
+				pm := float32(pm25[index].Value)
+				return &iface.AirQuality{AQIUS: &usa, AQIChina: &chn, PM25: &pm}
 			}(),
+		})
+	}
+	// the hourly forecast is shorter than the daily one, so days without
+	// hourly data only get their summary
+	hourlyDays := iface.GroupDays(slots, loc, len(slots))
+
+	for i := 0; i < numdays; i++ {
+		date, err := time.Parse(CAIYUNDATE_TMPL, weatherDailyData.Temperature[i].Date)
+		if err != nil {
+			return res, fmt.Errorf("%w: %v", iface.ErrParse, err)
+		}
+		dailyData := iface.Day{
+			Date:  date,
+			Slots: []iface.Cond{},
+		}
+		y, m, d := date.Date()
+		for _, day := range hourlyDays {
+			if dy, dm, dd := day.Date.Date(); dy == y && dm == m && dd == d {
+				dailyData.Slots = day.Slots
+			}
 		}
 
-		dateStr := weatherDailyData.Temperature[i].Date[0:10]
+		sunrise, err := caiyunParseClock(date, weatherDailyData.Astro[i].Sunrise.Time)
+		if err != nil {
+			return res, err
+		}
+		sunset, err := caiyunParseClock(date, weatherDailyData.Astro[i].Sunset.Time)
+		if err != nil {
+			return res, err
+		}
+		dailyData.Astronomy = iface.Astro{
+			Sunrise: sunrise,
+			Sunset:  sunset,
+		}
 
-		weatherHourlyData := weatherData.Result.Hourly
+		minTemp, maxTemp := float32(weatherDailyData.Temperature[i].Min), float32(weatherDailyData.Temperature[i].Max)
+		dailyData.Summary.MinTempC, dailyData.Summary.MaxTempC = &minTemp, &maxTemp
 
-		for index, houryTmp := range weatherData.Result.Hourly.Temperature {
-			if !strings.Contains(houryTmp.Datetime, dateStr) {
-				continue
-			}
-			dailyData.Slots = append(dailyData.Slots, iface.Cond{
-				TempC: func() *float32 {
-					x := float32(weatherData.Result.Hourly.Temperature[index].Value)
-					return &x
-				}(),
-				VisibleDistM: func() *float32 {
-					x := float32(weatherHourlyData.Visibility[index].Value)
-					return &x
-				}(),
-				Humidity: func() *int {
-					x := int(weatherHourlyData.Humidity[index].Value)
-					return &x
-				}(),
-				WindspeedKmph: func() *float32 {
-					x := float32(weatherHourlyData.Wind[index].Speed)
-					return &x
-				}(),
-				WinddirDegree: func() *int {
-					x := int(weatherHourlyData.Wind[index].Direction)
-					return &x
-				}(),
-				Time: func() time.Time {
-					x, err := time.Parse(CAIYUNDATE_TMPL, houryTmp.Datetime)
-					if err != nil {
-						panic(err)
-					}
-					return x
-				}(),
-				Code: func() iface.WeatherCode {
-					if code, ok := SkyconToIfaceCode[weatherHourlyData.Skycon[index].Value]; ok {
-						return code
-					} else {
-						return iface.CodeUnknown
-					}
-				}(),
-				PrecipM: func() *float32 {
-					x := float32(weatherHourlyData.Precipitation[index].Value) / 1000
-					return &x
-				}(),
-				FeelsLikeC: func() *float32 {
-					x := float32(weatherData.Result.Hourly.ApparentTemperature[index].Value)
-					return &x
-				}(),
-			})
+		if aqi, pm25 := weatherDailyData.AirQuality.Aqi, weatherDailyData.AirQuality.Pm25; i < len(aqi) && i < len(pm25) {
+			usa, chn := aqi[i].Max.Usa, aqi[i].Max.Chn
+			pm := float32(pm25[i].Max)
+			dailyData.AirQuality = &iface.AirQuality{AQIUS: &usa, AQIChina: &chn, PM25: &pm}
 		}
 
-		dailyDataSlice = append(dailyDataSlice, dailyData)
+		res.Forecast = append(res.Forecast, dailyData)
 	}
-	res.Forecast = dailyDataSlice
 
-	res.GeoLoc = &iface.LatLon{
-		Latitude:  float32(weatherData.Location[0]),
-		Longitude: float32(weatherData.Location[1]),
+	if len(weatherData.Location) == 2 {
+		res.GeoLoc = &iface.LatLon{
+			Latitude:  float32(weatherData.Location[0]),
+			Longitude: float32(weatherData.Location[1]),
+		}
 	}
-	return res
+	return res, nil
 }
 
 func init() {
@@ -330,6 +453,7 @@ func init() {
 
 type CaiyunWeather struct {
 	Status     string    `json:"status"`
+	Error      string    `json:"error"`
 	APIVersion string    `json:"api_version"`
 	APIStatus  string    `json:"api_status"`
 	Lang       string    `json:"lang"`
@@ -0,0 +1,395 @@
+package backends
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/schachmat/wego/geocode"
+	"github.com/schachmat/wego/iface"
+)
+
+type ensembleConfig struct {
+	members  string
+	backends map[string]iface.Backend
+}
+
+// EnsembleOptions configures the ensemble backend when it is used as a
+// library.
+type EnsembleOptions struct {
+	// Members lists the names of the backends to merge.
+	Members []string
+
+	// Backends maps the names in Members to the backends. It defaults to
+	// iface.AllBackends.
+	Backends map[string]iface.Backend
+}
+
+// New returns an ensemble backend configured by o.
+func (o EnsembleOptions) New() iface.Backend {
+	return &ensembleConfig{members: strings.Join(o.Members, ","), backends: o.Backends}
+}
+
+// ensembleMember is the forecast of one member of the ensemble.
+type ensembleMember struct {
+	name string
+	data iface.Data
+}
+
+func (c *ensembleConfig) Setup() {
+	flag.StringVar(&c.members, "ensemble-members", "smhi,openmeteo,openweathermap", "ensemble backend: comma separated `BACKENDS` whose forecasts are merged")
+}
+
+// Members returns the names of the backends which are merged.
+func (c *ensembleConfig) Members() []string {
+	names, _, _ := lookupMembers("ensemble", "ensemble-members", c.members, c.backends, c)
+	return names
+}
+
+// SupportedLocations returns the kinds supported by any of the backends.
+func (c *ensembleConfig) SupportedLocations() iface.LocationKind {
+	_, bes, err := lookupMembers("ensemble", "ensemble-members", c.members, c.backends, c)
+	if err != nil {
+		return iface.LocationAny
+	}
+	return memberLocations(bes)
+}
+
+// Fetch queries all backends concurrently and merges their forecasts. Every
+// value is the median of the values of the backends and the range of them is
+// kept in the Spread of the condition. Backends which fail or do not support
+// loc are left out. The errors of all of them are only returned if none
+// delivered a forecast. The data of every backend is kept in Members.
+func (c *ensembleConfig) Fetch(ctx context.Context, loc iface.Location, numdays int) (iface.Data, error) {
+	names, bes, err := lookupMembers("ensemble", "ensemble-members", c.members, c.backends, c)
+	if err != nil {
+		return iface.Data{}, err
+	}
+
+	results := make([]iface.Data, len(bes))
+	errs := make([]error, len(bes))
+	var wg sync.WaitGroup
+	for i, be := range bes {
+		loc := loc.For(be.SupportedLocations())
+		if err := loc.Check(names[i], be.SupportedLocations()); err != nil {
+			errs[i] = err
+			continue
+		}
+		wg.Add(1)
+		go func(i int, be iface.Backend, loc iface.Location) {
+			defer wg.Done()
+			results[i], errs[i] = be.Fetch(ctx, loc, numdays)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("%s: %w", names[i], errs[i])
+			}
+		}(i, be, loc)
+	}
+	wg.Wait()
+
+	var members []ensembleMember
+	for i := range bes {
+		if errs[i] == nil {
+			members = append(members, ensembleMember{names[i], results[i]})
+		}
+	}
+	if len(members) == 0 {
+		return iface.Data{}, errors.Join(errs...)
+	}
+	return mergeEnsemble(members, numdays), nil
+}
+
+// mergeEnsemble merges the forecasts of members. Location, coordinates and
+// time zone are taken from the first member which reports them, so the order
+// of the members matters. If none reports the time zone, the days are merged
+// in the zone at the coordinates, but it is left to the client to report it.
+func mergeEnsemble(members []ensembleMember, numdays int) (ret iface.Data) {
+	for _, m := range members {
+		if ret.GeoLoc == nil {
+			ret.GeoLoc = m.data.GeoLoc
+		}
+		if ret.TimeZone.Location == nil {
+			ret.TimeZone = m.data.TimeZone
+		}
+	}
+	tz := ret.TimeZone.Location
+	if tz == nil && ret.GeoLoc != nil {
+		// Like the client, merge the days of the time zone at the location.
+		// The zone is not reported, so the client can look it up with its own
+		// geocoder and regroup the days.
+		tz = geocode.TimeZoneAt(*ret.GeoLoc).Location
+	}
+	if tz == nil {
+		tz = time.UTC
+	}
+
+	var names []string
+	for i := range members {
+		// Members which left out the zone grouped their days in UTC and the
+		// zones of the others may differ, so their days are regrouped to line
+		// up the dates.
+		m := &members[i]
+		if m.data.TimeZone.Location == nil || m.data.TimeZone.String() != tz.String() {
+			m.data.TimeZone = iface.TimeZone{Location: tz}
+			m.data = m.data.Regroup(numdays)
+		}
+		names = append(names, m.name)
+		if ret.Location == "" {
+			ret.Location = m.data.Location
+		}
+		if len(ret.Nowcast) == 0 {
+			ret.Nowcast = m.data.Nowcast
+		}
+		if !m.data.Fetched.IsZero() && (ret.Fetched.IsZero() || m.data.Fetched.Before(ret.Fetched)) {
+			ret.Fetched = m.data.Fetched
+		}
+		ret.Stale = ret.Stale || m.data.Stale
+		ret.Alerts = mergeAlerts(ret.Alerts, m.data.Alerts)
+		member := m.data
+		if member.Source == "" {
+			member.Source = m.name
+		}
+		ret.Members = append(ret.Members, member)
+	}
+	ret.Source = "median of " + strings.Join(names, ", ")
+
+	var current []iface.Cond
+	for _, m := range members {
+		current = append(current, m.data.Current)
+	}
+	ret.Current = medianCond(current)
+	ret.Current.Time = members[0].data.Current.Time
+
+	// Resample all forecasts to hourly slots of the same time zone, so the
+	// slots of all members line up.
+	byTime := map[int64][]iface.Cond{}
+	for _, m := range members {
+		var slots []iface.Cond
+		for _, day := range m.data.Forecast {
+			for _, slot := range day.Slots {
+				slot.Time = slot.Time.In(tz)
+				slots = append(slots, slot)
+			}
+		}
+		for _, slot := range iface.Resample(slots, time.Hour) {
+			byTime[slot.Time.Unix()] = append(byTime[slot.Time.Unix()], slot)
+		}
+	}
+	var times []int64
+	for t := range byTime {
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	var slots []iface.Cond
+	for _, t := range times {
+		slot := medianCond(byTime[t])
+		slot.Time = time.Unix(t, 0).In(tz)
+		slots = append(slots, slot)
+	}
+
+	ret.Forecast = iface.GroupDays(slots, tz, numdays)
+	for i := range ret.Forecast {
+		day := &ret.Forecast[i]
+		var summaries []iface.Summary
+		for _, m := range members {
+			for _, md := range m.data.Forecast {
+				if !sameDate(md.Date.In(tz), day.Date) {
+					continue
+				}
+				summaries = append(summaries, md.Summary.Merge(iface.Summarize(md.Slots)))
+				if day.Astronomy.Sunrise.IsZero() && day.Astronomy.MoonPhase == iface.MoonPhaseUnknown {
+					day.Astronomy = md.Astronomy
+				}
+				if day.AirQuality == nil {
+					day.AirQuality = md.AirQuality
+				}
+			}
+		}
+		day.Summary = medianSummary(summaries)
+	}
+	return ret
+}
+
+// sameDate reports whether a and b are on the same calendar day.
+func sameDate(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// mergeAlerts appends the alerts of add to alerts which are not in there yet.
+func mergeAlerts(alerts, add []iface.Alert) []iface.Alert {
+	for _, a := range add {
+		dup := false
+		for _, b := range alerts {
+			if a.Title == b.Title && a.Onset.Equal(b.Onset) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts
+}
+
+// median returns the median, the minimum and the maximum of vs, which must not
+// be empty.
+func median(vs []float64) (med, min, max float64) {
+	sort.Float64s(vs)
+	n := len(vs)
+	med = vs[n/2]
+	if n%2 == 0 {
+		med = (vs[n/2-1] + vs[n/2]) / 2
+	}
+	return med, vs[0], vs[n-1]
+}
+
+// majority returns the most frequent of votes other than zero, which means
+// unknown for both weather codes and precipitation types. If several are
+// equally frequent, the one voted for first wins.
+func majority(votes []int) (ret int) {
+	count := map[int]int{}
+	for _, v := range votes {
+		count[v]++
+	}
+	for _, v := range votes {
+		if v != 0 && (ret == 0 || count[v] > count[ret]) {
+			ret = v
+		}
+	}
+	return ret
+}
+
+// medianCond returns the median of conds. Numeric fields are the median of the
+// conditions which carry them and their range is kept in Spread, which is nil
+// if none carries any. The wind direction is averaged along the circle.
+// Weather and precipitation type are the ones most conditions agree on.
+func medianCond(conds []iface.Cond) (ret iface.Cond) {
+	spread, spreadSet := &iface.Spread{}, false
+	float := func(field func(*iface.Cond) **float32) {
+		var vs []float64
+		for i := range conds {
+			if v := *field(&conds[i]); v != nil {
+				vs = append(vs, float64(*v))
+			}
+		}
+		if len(vs) == 0 {
+			return
+		}
+		med, min, max := median(vs)
+		m, lo, hi := float32(med), float32(min), float32(max)
+		*field(&ret), *field(&spread.Min), *field(&spread.Max) = &m, &lo, &hi
+		spreadSet = true
+	}
+	integer := func(field func(*iface.Cond) **int) {
+		var vs []float64
+		for i := range conds {
+			if v := *field(&conds[i]); v != nil {
+				vs = append(vs, float64(*v))
+			}
+		}
+		if len(vs) == 0 {
+			return
+		}
+		med, min, max := median(vs)
+		m, lo, hi := int(math.Round(med)), int(min), int(max)
+		*field(&ret), *field(&spread.Min), *field(&spread.Max) = &m, &lo, &hi
+		spreadSet = true
+	}
+
+	float(func(c *iface.Cond) **float32 { return &c.TempC })
+	float(func(c *iface.Cond) **float32 { return &c.FeelsLikeC })
+	integer(func(c *iface.Cond) **int { return &c.ChanceOfRainPercent })
+	float(func(c *iface.Cond) **float32 { return &c.PrecipM })
+	float(func(c *iface.Cond) **float32 { return &c.VisibleDistM })
+	float(func(c *iface.Cond) **float32 { return &c.WindspeedKmph })
+	float(func(c *iface.Cond) **float32 { return &c.WindGustKmph })
+	integer(func(c *iface.Cond) **int { return &c.Humidity })
+	float(func(c *iface.Cond) **float32 { return &c.PressureHPa })
+	float(func(c *iface.Cond) **float32 { return &c.DewPointC })
+	integer(func(c *iface.Cond) **int { return &c.CloudCoverPercent })
+	float(func(c *iface.Cond) **float32 { return &c.UVIndex })
+	float(func(c *iface.Cond) **float32 { return &c.SnowfallM })
+
+	var x, y float64
+	var codes, types []int
+	for _, c := range conds {
+		if c.WinddirDegree != nil {
+			rad := float64(*c.WinddirDegree) * math.Pi / 180
+			x, y = x+math.Cos(rad), y+math.Sin(rad)
+		}
+		codes = append(codes, int(c.Code))
+		types = append(types, int(c.PrecipType))
+		if ret.AirQuality == nil {
+			ret.AirQuality = c.AirQuality
+		}
+	}
+	if x != 0 || y != 0 {
+		deg := (int(math.Round(math.Atan2(y, x)*180/math.Pi)) + 360) % 360
+		ret.WinddirDegree = &deg
+	}
+	ret.Code = iface.WeatherCode(majority(codes))
+	ret.PrecipType = iface.PrecipType(majority(types))
+	for _, c := range conds {
+		if c.Code == ret.Code && c.Desc != "" {
+			ret.Desc = c.Desc
+			break
+		}
+	}
+	if spreadSet {
+		ret.Spread = spread
+	}
+	return ret
+}
+
+// medianSummary returns the median of summaries. The weather of the day is the
+// one most summaries agree on.
+func medianSummary(summaries []iface.Summary) (ret iface.Summary) {
+	float := func(field func(*iface.Summary) **float32) {
+		var vs []float64
+		for i := range summaries {
+			if v := *field(&summaries[i]); v != nil {
+				vs = append(vs, float64(*v))
+			}
+		}
+		if len(vs) > 0 {
+			med, _, _ := median(vs)
+			m := float32(med)
+			*field(&ret) = &m
+		}
+	}
+
+	float(func(s *iface.Summary) **float32 { return &s.MinTempC })
+	float(func(s *iface.Summary) **float32 { return &s.MaxTempC })
+	float(func(s *iface.Summary) **float32 { return &s.MinFeelsLikeC })
+	float(func(s *iface.Summary) **float32 { return &s.MaxFeelsLikeC })
+	float(func(s *iface.Summary) **float32 { return &s.PrecipSumM })
+	float(func(s *iface.Summary) **float32 { return &s.MaxWindGustKmph })
+
+	var chances []float64
+	var codes []int
+	for _, s := range summaries {
+		if s.MaxChanceOfRainPercent != nil {
+			chances = append(chances, float64(*s.MaxChanceOfRainPercent))
+		}
+		codes = append(codes, int(s.Code))
+	}
+	if len(chances) > 0 {
+		med, _, _ := median(chances)
+		m := int(math.Round(med))
+		ret.MaxChanceOfRainPercent = &m
+	}
+	ret.Code = iface.WeatherCode(majority(codes))
+	return ret
+}
+
+func init() {
+	iface.AllBackends["ensemble"] = &ensembleConfig{}
+}
@@ -0,0 +1,97 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		vs            []float64
+		med, min, max float64
+	}{
+		{[]float64{4}, 4, 4, 4},
+		{[]float64{3, 1, 2}, 2, 1, 3},
+		{[]float64{10, -2, 4, 1}, 2.5, -2, 10},
+		{[]float64{5, 5, 1, 5}, 5, 1, 5},
+	}
+
+	for _, test := range tests {
+		med, min, max := median(append([]float64(nil), test.vs...))
+		if med != test.med || min != test.min || max != test.max {
+			t.Errorf("median(%v) = %v, %v, %v, want %v, %v, %v", test.vs, med, min, max, test.med, test.min, test.max)
+		}
+	}
+}
+
+func TestMajority(t *testing.T) {
+	tests := []struct {
+		votes []int
+		want  int
+	}{
+		{nil, 0},
+		{[]int{0, 0}, 0},
+		{[]int{3}, 3},
+		{[]int{0, 0, 2}, 2},
+		{[]int{1, 2, 2}, 2},
+		{[]int{2, 1, 1, 2}, 2},
+		{[]int{1, 2, 1, 2}, 1},
+		{[]int{3, 0, 0, 1, 1}, 1},
+	}
+
+	for _, test := range tests {
+		if got := majority(test.votes); got != test.want {
+			t.Errorf("majority(%v) = %d, want %d", test.votes, got, test.want)
+		}
+	}
+}
+
+func TestMedianCond(t *testing.T) {
+	f := func(v float32) *float32 { return &v }
+	i := func(v int) *int { return &v }
+	conds := []iface.Cond{
+		{TempC: f(10), Humidity: i(80), WinddirDegree: i(350), Code: iface.CodeCloudy, Desc: "cloudy"},
+		{TempC: f(14), Humidity: i(71), WinddirDegree: i(30), Code: iface.CodeLightRain, Desc: "light rain", PrecipType: iface.PrecipRain},
+		{TempC: f(11), WinddirDegree: i(10), Code: iface.CodeLightRain, Desc: "drizzle"},
+	}
+	got := medianCond(conds)
+
+	if got.TempC == nil || *got.TempC != 11 {
+		t.Errorf("temperature %v, want 11", got.TempC)
+	}
+	if got.Spread == nil || *got.Spread.Min.TempC != 10 || *got.Spread.Max.TempC != 14 {
+		t.Fatalf("spread %+v, want 10 to 14 °C", got.Spread)
+	}
+	if got.Humidity == nil || *got.Humidity != 76 {
+		t.Errorf("humidity %v, want the rounded median 76 of the members forecasting it", got.Humidity)
+	}
+	if *got.Spread.Min.Humidity != 71 || *got.Spread.Max.Humidity != 80 {
+		t.Errorf("humidity spread %d to %d, want 71 to 80", *got.Spread.Min.Humidity, *got.Spread.Max.Humidity)
+	}
+	if got.WinddirDegree == nil || *got.WinddirDegree != 10 {
+		t.Errorf("wind direction %v, want 10 averaged along the circle", got.WinddirDegree)
+	}
+	if got.Code != iface.CodeLightRain || got.Desc != "light rain" {
+		t.Errorf("weather %v %q, want the majority %v with the description of its first member", got.Code, got.Desc, iface.CodeLightRain)
+	}
+	if got.PrecipType != iface.PrecipRain {
+		t.Errorf("precipitation type %v, want %v", got.PrecipType, iface.PrecipRain)
+	}
+	if got.FeelsLikeC != nil || got.Spread.Min.FeelsLikeC != nil || got.Spread.Max.FeelsLikeC != nil {
+		t.Errorf("feels like temperature set although no member forecast it")
+	}
+	if *conds[0].TempC != 10 || *conds[1].TempC != 14 {
+		t.Errorf("medianCond modified the conditions")
+	}
+}
+
+func TestMedianCondWithoutValues(t *testing.T) {
+	got := medianCond([]iface.Cond{{Code: iface.CodeSunny}, {Code: iface.CodeSunny, Desc: "clear"}})
+	if got.Spread != nil {
+		t.Errorf("spread %+v, want nil without numeric fields", got.Spread)
+	}
+	if got.Code != iface.CodeSunny || got.Desc != "clear" {
+		t.Errorf("weather %v %q, want %v %q", got.Code, got.Desc, iface.CodeSunny, "clear")
+	}
+}
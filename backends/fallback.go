@@ -0,0 +1,133 @@
+package backends
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type fallbackConfig struct {
+	order    string
+	backends map[string]iface.Backend
+}
+
+// FallbackOptions configures the fallback backend when it is used as a
+// library.
+type FallbackOptions struct {
+	// Order lists the names of the backends in the order they are tried.
+	Order []string
+
+	// Backends maps the names in Order to the backends. It defaults to
+	// iface.AllBackends.
+	Backends map[string]iface.Backend
+}
+
+// New returns a fallback backend configured by o.
+func (o FallbackOptions) New() iface.Backend {
+	return &fallbackConfig{order: strings.Join(o.Order, ","), backends: o.Backends}
+}
+
+func (c *fallbackConfig) Setup() {
+	flag.StringVar(&c.order, "fallback-order", "smhi,openmeteo,openweathermap", "fallback backend: comma separated `BACKENDS` tried in order until one delivers the forecast")
+}
+
+// members returns the names and backends of the fallback order.
+func (c *fallbackConfig) members() ([]string, []iface.Backend, error) {
+	return lookupMembers("fallback", "fallback-order", c.order, c.backends, c)
+}
+
+// Members returns the names of the backends in the fallback order.
+func (c *fallbackConfig) Members() []string {
+	names, _, _ := c.members()
+	return names
+}
+
+// lookupMembers returns the names and backends of the comma separated list of
+// members of the meta backend kind, which is configured with the flag option.
+// The backends are looked up in all or in iface.AllBackends if all is nil.
+func lookupMembers(kind, option, list string, all map[string]iface.Backend, self iface.Backend) (names []string, bes []iface.Backend, err error) {
+	if all == nil {
+		all = iface.AllBackends
+	}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		be, ok := all[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s backend: unknown backend %q in -%s", kind, name, option)
+		}
+		if be == self {
+			return nil, nil, fmt.Errorf("%s backend: -%s cannot contain the %s backend itself", kind, option, kind)
+		}
+		names, bes = append(names, name), append(bes, be)
+	}
+	if len(bes) == 0 {
+		return nil, nil, fmt.Errorf("%s backend: no backends configured, set them with -%s", kind, option)
+	}
+	return names, bes, nil
+}
+
+// SupportedLocations returns the kinds supported by any of the backends.
+func (c *fallbackConfig) SupportedLocations() iface.LocationKind {
+	_, bes, err := c.members()
+	if err != nil {
+		return iface.LocationAny
+	}
+	return memberLocations(bes)
+}
+
+// memberLocations returns the kinds supported by any of bes. Place names are
+// left out if one of them only supports coordinates, so they are turned into
+// coordinates before Fetch is called.
+func memberLocations(bes []iface.Backend) (ret iface.LocationKind) {
+	needsCoords := false
+	for _, be := range bes {
+		kinds := be.SupportedLocations()
+		ret |= kinds
+		if kinds&iface.LocationCoords != 0 && kinds&iface.LocationName == 0 {
+			needsCoords = true
+		}
+	}
+	if needsCoords && ret&iface.LocationCoords != 0 {
+		ret &^= iface.LocationName
+	}
+	return ret
+}
+
+// Fetch returns the data of the first backend which delivers it. The name of
+// that backend is recorded as the source of the data. If all backends fail,
+// the errors of all of them are returned.
+func (c *fallbackConfig) Fetch(ctx context.Context, loc iface.Location, numdays int) (iface.Data, error) {
+	names, bes, err := c.members()
+	if err != nil {
+		return iface.Data{}, err
+	}
+	var errs []error
+	for i, be := range bes {
+		loc := loc.For(be.SupportedLocations())
+		if err := loc.Check(names[i], be.SupportedLocations()); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		data, err := be.Fetch(ctx, loc, numdays)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", names[i], err))
+			continue
+		}
+		if data.Source == "" {
+			data.Source = names[i]
+		}
+		return data, nil
+	}
+	return iface.Data{}, errors.Join(errs...)
+}
+
+func init() {
+	iface.AllBackends["fallback"] = &fallbackConfig{}
+}
@@ -0,0 +1,168 @@
+package backends
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// fakeBackend answers with data or fails with err and records every call in
+// calls.
+type fakeBackend struct {
+	name  string
+	kinds iface.LocationKind
+	data  iface.Data
+	err   error
+	calls *[]string
+}
+
+func (f *fakeBackend) Setup() {}
+
+func (f *fakeBackend) SupportedLocations() iface.LocationKind {
+	return f.kinds
+}
+
+func (f *fakeBackend) Fetch(ctx context.Context, loc iface.Location, numdays int) (iface.Data, error) {
+	*f.calls = append(*f.calls, f.name)
+	return f.data, f.err
+}
+
+func TestFallbackFetch(t *testing.T) {
+	down := errors.New("down")
+	tests := []struct {
+		name    string
+		members []*fakeBackend
+		calls   string
+		source  string
+		errs    []string
+	}{
+		{
+			name: "first answers",
+			members: []*fakeBackend{
+				{name: "a", kinds: iface.LocationAny, data: iface.Data{Location: "A"}},
+				{name: "b", kinds: iface.LocationAny, data: iface.Data{Location: "B"}},
+			},
+			calls:  "a",
+			source: "a",
+		},
+		{
+			name: "in order until one answers",
+			members: []*fakeBackend{
+				{name: "a", kinds: iface.LocationAny, err: down},
+				{name: "b", kinds: iface.LocationAny, err: down},
+				{name: "c", kinds: iface.LocationAny, data: iface.Data{Location: "C"}},
+			},
+			calls:  "a,b,c",
+			source: "c",
+		},
+		{
+			name: "unsupported kinds skipped",
+			members: []*fakeBackend{
+				{name: "a", kinds: iface.LocationName, data: iface.Data{Location: "A"}},
+				{name: "b", kinds: iface.LocationCoords, data: iface.Data{Location: "B"}},
+			},
+			calls:  "b",
+			source: "b",
+		},
+		{
+			name: "source of the member kept",
+			members: []*fakeBackend{
+				{name: "a", kinds: iface.LocationAny, data: iface.Data{Location: "A", Source: "median of x, y"}},
+			},
+			calls:  "a",
+			source: "median of x, y",
+		},
+		{
+			name: "all fail",
+			members: []*fakeBackend{
+				{name: "a", kinds: iface.LocationName},
+				{name: "b", kinds: iface.LocationAny, err: iface.ErrQuota},
+				{name: "c", kinds: iface.LocationAny, err: down},
+			},
+			calls: "b,c",
+			errs:  []string{"the a backend does not support coordinate", "b: " + iface.ErrQuota.Error(), "c: down"},
+		},
+	}
+
+	loc, err := iface.ParseLocation("59.329,18.068")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls []string
+			all := map[string]iface.Backend{}
+			var order []string
+			for _, m := range test.members {
+				m.calls = &calls
+				all[m.name] = m
+				order = append(order, m.name)
+			}
+
+			data, err := FallbackOptions{Order: order, Backends: all}.New().Fetch(context.Background(), loc, 1)
+			if got := strings.Join(calls, ","); got != test.calls {
+				t.Errorf("called %s, want %s", got, test.calls)
+			}
+			if test.errs != nil {
+				if err == nil {
+					t.Fatal("no error")
+				}
+				lines := strings.Split(err.Error(), "\n")
+				for _, want := range test.errs {
+					found := false
+					for _, line := range lines {
+						found = found || strings.Contains(line, want)
+					}
+					if !found {
+						t.Errorf("error %q does not contain %q", err, want)
+					}
+				}
+				if !errors.Is(err, iface.ErrQuota) || !errors.Is(err, down) || !errors.Is(err, iface.ErrUnknownLocation) {
+					t.Errorf("error %q does not wrap the errors of all members", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data.Source != test.source {
+				t.Errorf("source %q, want %q", data.Source, test.source)
+			}
+		})
+	}
+}
+
+func TestLookupMembers(t *testing.T) {
+	self := &fakeBackend{}
+	all := map[string]iface.Backend{"a": &fakeBackend{}, "b": &fakeBackend{}, "self": self}
+	tests := []struct {
+		list  string
+		names string
+		err   string
+	}{
+		{"a,b", "a,b", ""},
+		{" b , a ,", "b,a", ""},
+		{"a,c", "", `unknown backend "c" in -fallback-order`},
+		{"a,self", "", "-fallback-order cannot contain the fallback backend itself"},
+		{" , ", "", "no backends configured, set them with -fallback-order"},
+	}
+
+	for _, test := range tests {
+		names, _, err := lookupMembers("fallback", "fallback-order", test.list, all, self)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("lookupMembers(%q): got error %v, want %q", test.list, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("lookupMembers(%q): %v", test.list, err)
+		}
+		if got := strings.Join(names, ","); got != test.names {
+			t.Errorf("lookupMembers(%q) = %s, want %s", test.list, got, test.names)
+		}
+	}
+}
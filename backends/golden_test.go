@@ -0,0 +1,110 @@
+package backends
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/httpclient"
+	"github.com/schachmat/wego/iface"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenTests fetch the weather with every backend from the responses recorded
+// in testdata/NAME/http, which can be created with the -record flag of wego.
+// The resulting data is compared to testdata/NAME/golden.json.
+var goldenTests = []struct {
+	name     string
+	backend  iface.Backend
+	location string
+	days     int
+}{
+	{"caiyun", CaiyunOptions{APIKey: "TESTKEY"}.New(), "39.9042,116.4074", 2},
+	{"ensemble", EnsembleOptions{
+		Members: []string{"openmeteo", "openweathermap"},
+		Backends: map[string]iface.Backend{
+			"openmeteo":      OpenMeteoOptions{}.New(),
+			"openweathermap": OpenWeatherMapOptions{APIKey: "TESTKEY"}.New(),
+		},
+	}.New(), "52.52,13.41", 2},
+	{"ensemble-no-timezone", EnsembleOptions{
+		Members: []string{"openweathermap", "smhi"},
+		Backends: map[string]iface.Backend{
+			"openweathermap": OpenWeatherMapOptions{APIKey: "TESTKEY"}.New(),
+			"smhi":           SMHIOptions{}.New(),
+		},
+	}.New(), "59.3293,18.068", 2},
+	{"json", JSONOptions{}.New(), "testdata/json/input.json", 2},
+	{"openmeteo", OpenMeteoOptions{}.New(), "52.52,13.41", 2},
+	{"openweathermap", OpenWeatherMapOptions{APIKey: "TESTKEY"}.New(), "Berlin", 2},
+	{"smhi", SMHIOptions{}.New(), "59.3293,18.068", 2},
+	{"worldweatheronline", WorldWeatherOnlineOptions{APIKey: "TESTKEY"}.New(), "London", 2},
+}
+
+func TestGolden(t *testing.T) {
+	now = func() time.Time { return time.Date(2026, 10, 17, 6, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	for _, test := range goldenTests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := filepath.Join("testdata", test.name)
+			client := httpclient.Options{
+				Transport: &httpclient.Replayer{Dir: filepath.Join(dir, "http")},
+			}.New()
+			ctx := httpclient.NewContext(context.Background(), client)
+
+			loc, err := iface.ParseLocation(test.location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := test.backend.Fetch(ctx, loc, test.days)
+			if err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+			got, err := json.MarshalIndent(data, "", "\t")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join(dir, "golden.json")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("data differs from %s, run go test -update and review the diff:\n%s", golden, firstDiff(got, want))
+			}
+		})
+	}
+}
+
+// firstDiff returns the first line which differs between got and want.
+func firstDiff(got, want []byte) string {
+	g, w := bytes.Split(got, []byte("\n")), bytes.Split(want, []byte("\n"))
+	for i := 0; i < len(g) || i < len(w); i++ {
+		var gl, wl []byte
+		if i < len(g) {
+			gl = g[i]
+		}
+		if i < len(w) {
+			wl = w[i]
+		}
+		if !bytes.Equal(gl, wl) {
+			return fmt.Sprintf("line %d:\n got: %s\nwant: %s", i+1, gl, wl)
+		}
+	}
+	return ""
+}
@@ -1,9 +1,10 @@
 package backends
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"os"
-	"log"
 
 	"github.com/schachmat/wego/iface"
 )
@@ -11,28 +12,44 @@ import (
 type jsnConfig struct {
 }
 
+// JSONOptions configures the json backend when it is used as a library. The
+// backend has no options yet.
+type JSONOptions struct{}
+
+// New returns a json backend, which reads the weather data from the file
+// passed as location.
+func (o JSONOptions) New() iface.Backend {
+	return &jsnConfig{}
+}
+
 func (c *jsnConfig) Setup() {
 }
 
-// Fetch will try to open the file specified in the location string argument and
+// SupportedLocations returns all kinds, because any location is taken as a
+// file name.
+func (c *jsnConfig) SupportedLocations() iface.LocationKind {
+	return iface.LocationAny
+}
+
+// Fetch will try to open the file specified in the raw location argument and
 // read it as json content to fill the data. The numdays argument will only work
 // to further limit the amount of days in the output. It obviously cannot
 // produce more data than is available in the file.
-func (c *jsnConfig) Fetch(loc string, numdays int) (ret iface.Data) {
-	b, err := os.ReadFile(loc)
+func (c *jsnConfig) Fetch(ctx context.Context, loc iface.Location, numdays int) (ret iface.Data, err error) {
+	b, err := os.ReadFile(loc.Raw)
 	if err != nil {
-		log.Fatal(err)
+		return ret, fmt.Errorf("%w: %v", iface.ErrUnknownLocation, err)
 	}
 
 	err = json.Unmarshal(b, &ret)
 	if err != nil {
-		log.Fatal(err)
+		return ret, fmt.Errorf("%w: %v", iface.ErrParse, err)
 	}
 
 	if len(ret.Forecast) > numdays {
 		ret.Forecast = ret.Forecast[:numdays]
 	}
-	return
+	return ret, nil
 }
 
 func init() {
@@ -1,16 +1,15 @@
 package backends
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"regexp"
 	"strings"
 	"time"
 
+	"github.com/schachmat/wego/httpclient"
 	"github.com/schachmat/wego/iface"
 )
 
@@ -18,6 +17,27 @@ type openmeteoConfig struct {
 	apiKey   string
 	language string
 	debug    bool
+	baseURL  string
+}
+
+// OpenMeteoOptions configures the openmeteo backend when it is used as a
+// library.
+type OpenMeteoOptions struct {
+	// APIKey is the open-meteo.com api key for commercial usage. It can be
+	// left empty for non-commercial usage.
+	APIKey string
+
+	// Debug prints raw requests and responses.
+	Debug bool
+
+	// BaseURL replaces the scheme and host of the api, e.g. to use a mirror or
+	// a test server.
+	BaseURL string
+}
+
+// New returns an openmeteo backend configured by o.
+func (o OpenMeteoOptions) New() iface.Backend {
+	return &openmeteoConfig{apiKey: o.APIKey, debug: o.Debug, baseURL: o.BaseURL}
 }
 
 type curCond struct {
@@ -28,15 +48,25 @@ type curCond struct {
 	IsDay               int      `json:"is_day"`
 	WeatherCode         int      `json:"weather_code"`
 	WindDirection10M    *int     `json:"wind_direction_10m"`
+	PressureMsl         *float32 `json:"pressure_msl"`
+	DewPoint2M          *float32 `json:"dew_point_2m"`
+	CloudCover          *int     `json:"cloud_cover"`
+	UVIndex             *float32 `json:"uv_index"`
+	Snowfall            *float32 `json:"snowfall"`
 }
 
 type Daily struct {
-	Time                   []int64    `json:"time"`
-	WeatherCode            []int      `json:"weather_code"`
-	Temperature2MMax       []*float32 `json:"temperature_2m_max"`
-	ApparentTemperatureMax []*float32 `json:"apparent_temperature_max"`
-	Sunrise                []int64    `json:"sunrise"`
-	Sunset                 []int64    `json:"sunset"`
+	Time                        []int64    `json:"time"`
+	WeatherCode                 []int      `json:"weather_code"`
+	Temperature2MMax            []*float32 `json:"temperature_2m_max"`
+	Temperature2MMin            []*float32 `json:"temperature_2m_min"`
+	ApparentTemperatureMax      []*float32 `json:"apparent_temperature_max"`
+	ApparentTemperatureMin      []*float32 `json:"apparent_temperature_min"`
+	PrecipitationSum            []*float32 `json:"precipitation_sum"`
+	WindGusts10MMax             []*float32 `json:"wind_gusts_10m_max"`
+	PrecipitationProbabilityMax []*int     `json:"precipitation_probability_max"`
+	Sunrise                     []int64    `json:"sunrise"`
+	Sunset                      []int64    `json:"sunset"`
 }
 type HourlyUnits struct {
 	Time                string `json:"time"`
@@ -50,6 +80,16 @@ type Hourly struct {
 	ApparentTemperature []*float32 `json:"apparent_temperature"`
 	WeatherCode         []int      `json:"weather_code"`
 	WindDirection10M    []*int     `json:"wind_direction_10m"`
+	PressureMsl         []*float32 `json:"pressure_msl"`
+	DewPoint2M          []*float32 `json:"dew_point_2m"`
+	CloudCover          []*int     `json:"cloud_cover"`
+	UVIndex             []*float32 `json:"uv_index"`
+	Snowfall            []*float32 `json:"snowfall"`
+}
+
+type Minutely15 struct {
+	Time          []int64    `json:"time"`
+	Precipitation []*float32 `json:"precipitation"`
 }
 
 type openmeteoResponse struct {
@@ -70,6 +110,7 @@ type openmeteoResponse struct {
 	} `json:"current_units"`
 	Current     curCond     `json:"current"`
 	HourlyUnits HourlyUnits `json:"hourly_units"`
+	Minutely15  Minutely15  `json:"minutely_15"`
 	Hourly      Hourly      `json:"hourly"`
 	DailyUnits  struct {
 		Time                   string `json:"time"`
@@ -107,42 +148,116 @@ var (
 	}
 )
 
+// openmeteoPrecipType returns the kind of precipitation of the WMO weather code.
+func openmeteoPrecipType(code int) iface.PrecipType {
+	switch {
+	case code == 56 || code == 57 || code == 66 || code == 67:
+		return iface.PrecipFreezingRain
+	case code >= 51 && code <= 55:
+		return iface.PrecipDrizzle
+	case code >= 61 && code <= 65, code >= 80 && code <= 82, code == 95:
+		return iface.PrecipRain
+	case code >= 71 && code <= 77, code == 85 || code == 86:
+		return iface.PrecipSnow
+	case code == 96 || code == 99:
+		return iface.PrecipHail
+	case code < 50:
+		return iface.PrecipNone
+	}
+	return iface.PrecipUnknown
+}
+
+// cmToMPerHour converts a snowfall sum in cm over interval seconds to m/h.
+func cmToMPerHour(cm *float32, interval int) *float32 {
+	if cm == nil || interval <= 0 {
+		return nil
+	}
+	m := *cm / 100 * 3600 / float32(interval)
+	return &m
+}
+
+// parseMinutely15 converts the 15 minute precipitation sums to intensities.
+func parseMinutely15(minutely Minutely15) (ret []iface.Cond) {
+	for i, t := range minutely.Time {
+		if i >= len(minutely.Precipitation) {
+			break
+		}
+		slot := iface.Cond{Time: time.Unix(t, 0)}
+		if p := minutely.Precipitation[i]; p != nil {
+			mh := *p / 1000 * 4 // mm per 15 minutes to m per hour
+			slot.PrecipM = &mh
+		}
+		ret = append(ret, slot)
+	}
+	return ret
+}
+
+// openmeteoSummary returns the summary of the i-th day of the daily data.
+func openmeteoSummary(daily Daily, i int) (ret iface.Summary) {
+	at := func(vals []*float32) *float32 {
+		if i < len(vals) {
+			return vals[i]
+		}
+		return nil
+	}
+	ret.MinTempC = at(daily.Temperature2MMin)
+	ret.MaxTempC = at(daily.Temperature2MMax)
+	ret.MinFeelsLikeC = at(daily.ApparentTemperatureMin)
+	ret.MaxFeelsLikeC = at(daily.ApparentTemperatureMax)
+	ret.MaxWindGustKmph = at(daily.WindGusts10MMax)
+	if p := at(daily.PrecipitationSum); p != nil {
+		m := *p / 1000
+		ret.PrecipSumM = &m
+	}
+	if i < len(daily.PrecipitationProbabilityMax) {
+		ret.MaxChanceOfRainPercent = daily.PrecipitationProbabilityMax[i]
+	}
+	if i < len(daily.WeatherCode) {
+		ret.Code = codemap[daily.WeatherCode[i]]
+	}
+	return ret
+}
+
 func (opmeteo *openmeteoConfig) Setup() {
 	flag.StringVar(&opmeteo.apiKey, "openmeteo-api-key", "", "openmeteo backend: the api `KEY` to use if commercial usage")
 	flag.BoolVar(&opmeteo.debug, "openmeteo-debug", false, "openmeteo backend: print raw requests and responses")
+	flag.StringVar(&opmeteo.baseURL, "openmeteo-base-url", "", "openmeteo backend: the `URL` of a mirror or test server to use instead of api.open-meteo.com")
 }
 
-func (opmeteo *openmeteoConfig) parseDaily(dailyInfo Hourly) []iface.Day {
-	var forecast []iface.Day
-	var day *iface.Day
-
+// parseDaily groups the hourly slots into numdays days of the time zone loc.
+func (opmeteo *openmeteoConfig) parseDaily(dailyInfo Hourly, numdays int, loc *time.Location) ([]iface.Day, error) {
+	if n := len(dailyInfo.Time); len(dailyInfo.WeatherCode) < n || len(dailyInfo.Temperature2M) < n ||
+		len(dailyInfo.ApparentTemperature) < n || len(dailyInfo.WindDirection10M) < n {
+		return nil, fmt.Errorf("%w: hourly forecast incomplete", iface.ErrParse)
+	}
+	var slots []iface.Cond
 	for ind, dayTime := range dailyInfo.Time {
-
 		cond := new(iface.Cond)
+		// The optional variables may be missing or shorter than Time.
+		at := func(vals []*float32) *float32 {
+			if ind < len(vals) {
+				return vals[ind]
+			}
+			return nil
+		}
 
 		cond.Code = codemap[dailyInfo.WeatherCode[ind]]
 		cond.TempC = dailyInfo.Temperature2M[ind]
 		cond.FeelsLikeC = dailyInfo.ApparentTemperature[ind]
 		cond.Time = time.Unix(dayTime, 0)
 		cond.WinddirDegree = dailyInfo.WindDirection10M[ind]
-
-		if day == nil {
-			day = new(iface.Day)
-			day.Date = cond.Time
-		}
-		if day.Date.Day() == cond.Time.Day() {
-			day.Slots = append(day.Slots, *cond)
+		cond.PrecipType = openmeteoPrecipType(dailyInfo.WeatherCode[ind])
+		cond.PressureHPa = at(dailyInfo.PressureMsl)
+		cond.DewPointC = at(dailyInfo.DewPoint2M)
+		if ind < len(dailyInfo.CloudCover) {
+			cond.CloudCoverPercent = dailyInfo.CloudCover[ind]
 		}
-		if day.Date.Day() != cond.Time.Day() {
-			forecast = append(forecast, *day)
+		cond.UVIndex = at(dailyInfo.UVIndex)
+		cond.SnowfallM = cmToMPerHour(at(dailyInfo.Snowfall), 3600)
 
-			day = new(iface.Day)
-			day.Date = cond.Time
-			day.Slots = append(day.Slots, *cond)
-		}
+		slots = append(slots, *cond)
 	}
-
-	return forecast
+	return iface.GroupDays(slots, loc, numdays), nil
 }
 
 func parseCurCond(current curCond) (ret iface.Cond) {
@@ -157,44 +272,44 @@ func parseCurCond(current curCond) (ret iface.Cond) {
 	ret.TempC = current.Temperature2M
 	ret.FeelsLikeC = current.ApparentTemperature
 	ret.WinddirDegree = current.WindDirection10M
+	ret.PrecipType = openmeteoPrecipType(current.WeatherCode)
+	ret.PressureHPa = current.PressureMsl
+	ret.DewPointC = current.DewPoint2M
+	ret.CloudCoverPercent = current.CloudCover
+	ret.UVIndex = current.UVIndex
+	ret.SnowfallM = cmToMPerHour(current.Snowfall, current.Interval)
 	return ret
 
 }
 
-func (opmeteo *openmeteoConfig) Fetch(location string, numdays int) iface.Data {
+func (opmeteo *openmeteoConfig) SupportedLocations() iface.LocationKind {
+	return iface.LocationCoords
+}
+
+func (opmeteo *openmeteoConfig) Fetch(ctx context.Context, location iface.Location, numdays int) (iface.Data, error) {
 	var ret iface.Data
 	var params []string
-	var loc string
-
-	if numdays <= 0 {
-		log.Fatal("Number of days less than 1 ")
-	}
 
-	if matched, err := regexp.MatchString(`^-?[0-9]*(\.[0-9]+)?,-?[0-9]*(\.[0-9]+)?$`, location); matched && err == nil {
-		s := strings.Split(location, ",")
-		loc = fmt.Sprintf("latitude=%s&longitude=%s", s[0], s[1])
+	if err := location.Check("openmeteo", opmeteo.SupportedLocations()); err != nil {
+		return ret, err
 	}
-	if len(location) > 0 {
-		params = append(params, loc)
-	}
-	params = append(params, "current=temperature_2m,apparent_temperature,is_day,weather_code,wind_direction_10m")
-	params = append(params, "hourly=temperature_2m,apparent_temperature,weather_code,wind_direction_10m")
-	params = append(params, "daily=weather_code,temperature_2m_max,apparent_temperature_max,sunrise,sunset")
-	params = append(params, fmt.Sprintf("timeformat=unixtime&forecast_days=%d", numdays))
 
-	requri := openmeteoURI + strings.Join(params, "&")
+	lat, lon := location.Coords()
+	params = append(params, fmt.Sprintf("latitude=%s&longitude=%s", lat, lon))
+	params = append(params, "current=temperature_2m,apparent_temperature,is_day,weather_code,wind_direction_10m,pressure_msl,dew_point_2m,cloud_cover,uv_index,snowfall")
+	params = append(params, "hourly=temperature_2m,apparent_temperature,weather_code,wind_direction_10m,pressure_msl,dew_point_2m,cloud_cover,uv_index,snowfall")
+	params = append(params, "daily=weather_code,temperature_2m_max,temperature_2m_min,apparent_temperature_max,apparent_temperature_min,precipitation_sum,wind_gusts_10m_max,precipitation_probability_max,sunrise,sunset")
+	params = append(params, "minutely_15=precipitation&forecast_minutely_15=8")
+	params = append(params, fmt.Sprintf("timeformat=unixtime&timezone=auto&forecast_days=%d", numdays))
 
-	res, err := http.Get(requri)
+	requri, err := rebase(openmeteoURI+strings.Join(params, "&"), opmeteo.baseURL)
 	if err != nil {
-		log.Fatal("Unable to get weather data: ", err)
-	} else if res.StatusCode != 200 {
-		log.Fatal("Unable to get weather data: http status ", res.StatusCode)
+		return ret, err
 	}
-	defer res.Body.Close()
 
-	body, err := io.ReadAll(res.Body)
+	body, err := httpclient.Get(ctx, requri)
 	if err != nil {
-		log.Fatal(err)
+		return ret, err
 	}
 
 	if opmeteo.debug {
@@ -205,22 +320,37 @@ func (opmeteo *openmeteoConfig) Fetch(location string, numdays int) iface.Data {
 
 	var resp openmeteoResponse
 	if err = json.Unmarshal(body, &resp); err != nil {
-		log.Println(err)
+		return ret, fmt.Errorf("%w: %v", iface.ErrParse, err)
 	}
 
 	ret.Current = parseCurCond(resp.Current)
-	ret.Location = location
+	ret.Nowcast = parseMinutely15(resp.Minutely15)
+	ret.GeoLoc = &iface.LatLon{Latitude: float32(resp.Latitude), Longitude: float32(resp.Longitude)}
+
+	ret.TimeZone, err = iface.LoadTimeZone(resp.Timezone)
+	if err != nil {
+		ret.TimeZone = iface.FixedTimeZone(resp.UtcOffsetSeconds)
+	}
 
-	forecast := opmeteo.parseDaily(resp.Hourly)
+	forecast, err := opmeteo.parseDaily(resp.Hourly, numdays, ret.TimeZone.Location)
+	if err != nil {
+		return ret, err
+	}
 
-	for i, _ := range forecast {
+	for i := range forecast {
+		if i >= len(resp.Daily.Sunrise) || i >= len(resp.Daily.Sunset) {
+			break
+		}
 		forecast[i].Astronomy.Sunset = time.Unix(resp.Daily.Sunset[i], 0)
 		forecast[i].Astronomy.Sunrise = time.Unix(resp.Daily.Sunrise[i], 0)
 	}
+	for i := range forecast {
+		forecast[i].Summary = openmeteoSummary(resp.Daily, i)
+	}
 	if len(forecast) > 0 {
 		ret.Forecast = forecast
 	}
-	return ret
+	return ret, nil
 }
 
 func init() {
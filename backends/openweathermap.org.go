@@ -1,33 +1,64 @@
 package backends
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"github.com/schachmat/wego/iface"
-	"io"
 	"log"
-	"net/http"
-	"regexp"
-	"strings"
+	"net/url"
 	"time"
+
+	"github.com/schachmat/wego/httpclient"
+	"github.com/schachmat/wego/iface"
 )
 
 type openWeatherConfig struct {
-	apiKey string
-	lang   string
-	debug  bool
+	apiKey  string
+	lang    string
+	debug   bool
+	baseURL string
+}
+
+// OpenWeatherMapOptions configures the openweathermap backend when it is used
+// as a library.
+type OpenWeatherMapOptions struct {
+	// APIKey is the openweathermap.org api key to use.
+	APIKey string
+
+	// Lang is the language of the weather descriptions. Defaults to "en".
+	Lang string
+
+	// Debug prints raw requests and responses.
+	Debug bool
+
+	// BaseURL replaces the scheme and host of the api, e.g. to use a mirror or
+	// a test server.
+	BaseURL string
+}
+
+// New returns an openweathermap backend configured by o.
+func (o OpenWeatherMapOptions) New() iface.Backend {
+	c := &openWeatherConfig{apiKey: o.APIKey, lang: o.Lang, debug: o.Debug, baseURL: o.BaseURL}
+	if c.lang == "" {
+		c.lang = "en"
+	}
+	return c
 }
 
 type openWeatherResponse struct {
 	Cod  string `json:"cod"`
 	City struct {
-		Name    string `json:"name"`
-		Country string `json:"country"`
-		TimeZone int64 `json: "timezone"`
+		Name     string `json:"name"`
+		Country  string `json:"country"`
+		TimeZone int64  `json:"timezone"` // current offset from UTC in seconds
+		Coord    struct {
+			Lat float32 `json:"lat"`
+			Lon float32 `json:"lon"`
+		} `json:"coord"`
 		// sunrise/sunset are once per call
-		SunRise int64 `json: "sunrise"`
-		SunSet int64 `json: "sunset"`
+		SunRise int64 `json:"sunrise"`
+		SunSet  int64 `json:"sunset"`
 	} `json:"city"`
 	List []dataBlock `json:"list"`
 }
@@ -38,6 +69,7 @@ type dataBlock struct {
 		TempC      float32 `json:"temp"`
 		FeelsLikeC float32 `json:"feels_like"`
 		Humidity   int     `json:"humidity"`
+		Pressure   float32 `json:"pressure"`
 	} `json:"main"`
 
 	Weather []struct {
@@ -53,75 +85,64 @@ type dataBlock struct {
 	Rain struct {
 		MM3h float32 `json:"3h"`
 	} `json:"rain"`
+
+	Snow struct {
+		MM3h float32 `json:"3h"`
+	} `json:"snow"`
+
+	Clouds struct {
+		All *int `json:"all"`
+	} `json:"clouds"`
 }
 
 const (
-	openweatherURI = "http://api.openweathermap.org/data/2.5/forecast?%s&appid=%s&units=metric&lang=%s"
+	openweatherURI = "https://api.openweathermap.org/data/2.5/forecast?%s&appid=%s&units=metric&lang=%s"
 )
 
 func (c *openWeatherConfig) Setup() {
 	flag.StringVar(&c.apiKey, "owm-api-key", "", "openweathermap backend: the api `KEY` to use")
 	flag.StringVar(&c.lang, "owm-lang", "en", "openweathermap backend: the `LANGUAGE` to request from openweathermap")
 	flag.BoolVar(&c.debug, "owm-debug", false, "openweathermap backend: print raw requests and responses")
+	flag.StringVar(&c.baseURL, "owm-base-url", "", "openweathermap backend: the `URL` of a mirror or test server to use instead of api.openweathermap.org")
 }
 
-func (c *openWeatherConfig) fetch(url string) (*openWeatherResponse, error) {
-	res, err := http.Get(url)
+func (c *openWeatherConfig) fetch(ctx context.Context, url string) (*openWeatherResponse, error) {
 	if c.debug {
 		fmt.Printf("Fetching %s\n", url)
 	}
-	if err != nil {
-		return nil, fmt.Errorf(" Unable to get (%s) %v", url, err)
+	body, err := httpclient.Get(ctx, url)
+	if c.debug && body != nil {
+		fmt.Printf("Response (%s):\n%s\n", url, string(body))
 	}
-	defer res.Body.Close()
-	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to read response body (%s): %v", url, err)
-	}
-
-	if c.debug {
-		fmt.Printf("Response (%s):\n%s\n", url, string(body))
+		return nil, err
 	}
 
 	var resp openWeatherResponse
 	if err = json.Unmarshal(body, &resp); err != nil {
-		return nil, fmt.Errorf("Unable to unmarshal response (%s): %v\nThe json body is: %s", url, err, string(body))
+		return nil, fmt.Errorf("%w: unable to unmarshal response (%s): %v\nThe json body is: %s", iface.ErrParse, url, err, string(body))
 	}
 	if resp.Cod != "200" {
-		return nil, fmt.Errorf("Erroneous response body: %s", string(body))
+		return nil, fmt.Errorf("%w: erroneous response body: %s", iface.ErrUpstream, string(body))
+	}
+	if len(resp.List) == 0 {
+		return nil, fmt.Errorf("%w: no weather data in response: %s", iface.ErrParse, string(body))
 	}
 	return &resp, nil
 }
 
-func (c *openWeatherConfig) parseDaily(dataInfo []dataBlock, numdays int) []iface.Day {
-	var forecast []iface.Day
-	var day *iface.Day
-
+// parseDaily groups the forecast slots into days of the time zone loc.
+func (c *openWeatherConfig) parseDaily(dataInfo []dataBlock, numdays int, loc *time.Location) []iface.Day {
+	var slots []iface.Cond
 	for _, data := range dataInfo {
 		slot, err := c.parseCond(data)
 		if err != nil {
 			log.Println("Error parsing hourly weather condition:", err)
 			continue
 		}
-		if day == nil {
-			day = new(iface.Day)
-			day.Date = slot.Time
-		}
-		if day.Date.Day() == slot.Time.Day() {
-			day.Slots = append(day.Slots, slot)
-		}
-		if day.Date.Day() != slot.Time.Day() {
-			forecast = append(forecast, *day)
-			if len(forecast) >= numdays {
-				break
-			}
-			day = new(iface.Day)
-			day.Date = slot.Time
-			day.Slots = append(day.Slots, slot)
-		}
-
+		slots = append(slots, slot)
 	}
-	return forecast
+	return iface.GroupDays(slots, loc, numdays)
 }
 
 func (c *openWeatherConfig) parseCond(dataInfo dataBlock) (iface.Cond, error) {
@@ -202,6 +223,10 @@ func (c *openWeatherConfig) parseCond(dataInfo dataBlock) (iface.Cond, error) {
 		962: iface.CodeUnknown, // hurricane
 	}
 
+	if len(dataInfo.Weather) == 0 {
+		return ret, fmt.Errorf("%w: no weather description for %d", iface.ErrParse, dataInfo.Dt)
+	}
+
 	ret.Code = iface.CodeUnknown
 	ret.Desc = dataInfo.Weather[0].Description
 	ret.Humidity = &(dataInfo.Main.Humidity)
@@ -224,51 +249,95 @@ func (c *openWeatherConfig) parseCond(dataInfo dataBlock) (iface.Cond, error) {
 		ret.PrecipM = &mmh
 	}
 
+	if dataInfo.Snow.MM3h > 0 {
+		mmh := (dataInfo.Snow.MM3h / 1000) / 3
+		ret.SnowfallM = &mmh
+	}
+
+	if dataInfo.Main.Pressure > 0 {
+		ret.PressureHPa = &dataInfo.Main.Pressure
+	}
+	ret.CloudCoverPercent = dataInfo.Clouds.All
+
+	switch id := dataInfo.Weather[0].ID; {
+	case id == 511:
+		ret.PrecipType = iface.PrecipFreezingRain
+	case id >= 611 && id <= 616:
+		ret.PrecipType = iface.PrecipSleet
+	case id >= 300 && id < 400:
+		ret.PrecipType = iface.PrecipDrizzle
+	case id >= 200 && id < 600:
+		ret.PrecipType = iface.PrecipRain
+	case id >= 600 && id < 700:
+		ret.PrecipType = iface.PrecipSnow
+	case id >= 700:
+		ret.PrecipType = iface.PrecipNone
+	}
+
 	ret.Time = time.Unix(dataInfo.Dt, 0)
 
 	return ret, nil
 }
 
-func (c *openWeatherConfig) Fetch(location string, numdays int) iface.Data {
+// Language returns the language the weather descriptions are requested in.
+func (c *openWeatherConfig) Language() string {
+	return c.lang
+}
+
+func (c *openWeatherConfig) SupportedLocations() iface.LocationKind {
+	return iface.LocationCoords | iface.LocationName | iface.LocationPostal
+}
+
+func (c *openWeatherConfig) Fetch(ctx context.Context, location iface.Location, numdays int) (iface.Data, error) {
 	var ret iface.Data
 	loc := ""
 
 	if len(c.apiKey) == 0 {
-		log.Fatal("No openweathermap.org API key specified.\nYou have to register for one at https://home.openweathermap.org/users/sign_up")
+		return ret, fmt.Errorf("%w: no openweathermap.org API key specified.\nYou have to register for one at https://home.openweathermap.org/users/sign_up", iface.ErrAuth)
+	}
+	if err := location.Check("openweathermap", c.SupportedLocations()); err != nil {
+		return ret, err
 	}
-	if matched, err := regexp.MatchString(`^-?[0-9]*(\.[0-9]+)?,-?[0-9]*(\.[0-9]+)?$`, location); matched && err == nil {
-		s := strings.Split(location, ",")
-		loc = fmt.Sprintf("lat=%s&lon=%s", s[0], s[1])
-	} else if matched, err = regexp.MatchString(`^[0-9].*`, location); matched && err == nil {
-		loc = "zip=" + location
-	} else {
-		loc = "q=" + location
+	switch location.Kind {
+	case iface.LocationCoords:
+		lat, lon := location.Coords()
+		loc = fmt.Sprintf("lat=%s&lon=%s", lat, lon)
+	case iface.LocationPostal:
+		loc = "zip=" + url.QueryEscape(location.String())
+	default:
+		loc = "q=" + url.QueryEscape(location.Name)
 	}
 
-	resp, err := c.fetch(fmt.Sprintf(openweatherURI, loc, c.apiKey, c.lang))
+	uri, err := rebase(fmt.Sprintf(openweatherURI, loc, c.apiKey, c.lang), c.baseURL)
 	if err != nil {
-		log.Fatalf("Failed to fetch weather data: %v\n", err)
+		return ret, err
+	}
+	resp, err := c.fetch(ctx, uri)
+	if err != nil {
+		return ret, err
 	}
 	ret.Current, err = c.parseCond(resp.List[0])
-	ret.Location = fmt.Sprintf("%s, %s", resp.City.Name, resp.City.Country)
-
 	if err != nil {
-		log.Fatalf("Failed to fetch weather data: %v\n", err)
+		return ret, err
 	}
+	ret.Location = fmt.Sprintf("%s, %s", resp.City.Name, resp.City.Country)
+	ret.GeoLoc = &iface.LatLon{Latitude: resp.City.Coord.Lat, Longitude: resp.City.Coord.Lon}
 
 	if numdays == 0 {
-		return ret
+		return ret, nil
 	}
-	ret.Forecast = c.parseDaily(resp.List, numdays)
+	// openweathermap only reports the current UTC offset, which changes with
+	// daylight saving time, so the client looks up the time zone and groups
+	// the days in it
+	ret.Forecast = c.parseDaily(resp.List, numdays, time.UTC)
 
 	// add in the sunrise/sunset information to the first day
-	// these maybe should deal with resp.City.TimeZone
 	if len(ret.Forecast) > 0 {
 		ret.Forecast[0].Astronomy.Sunrise = time.Unix(resp.City.SunRise, 0)
 		ret.Forecast[0].Astronomy.Sunset = time.Unix(resp.City.SunSet, 0)
 	}
 
-	return ret
+	return ret, nil
 }
 
 func init() {
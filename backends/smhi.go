@@ -1,18 +1,30 @@
 package backends
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"github.com/schachmat/wego/iface"
-	"io"
-	"log"
-	"net/http"
-	"regexp"
-	"strings"
 	"time"
+
+	"github.com/schachmat/wego/httpclient"
+	"github.com/schachmat/wego/iface"
 )
 
 type smhiConfig struct {
+	baseURL string
+}
+
+// SMHIOptions configures the smhi backend when it is used as a library.
+type SMHIOptions struct {
+	// BaseURL replaces the scheme and host of the api, e.g. to use a mirror or
+	// a test server.
+	BaseURL string
+}
+
+// New returns a smhi backend configured by o.
+func (o SMHIOptions) New() iface.Backend {
+	return &smhiConfig{baseURL: o.BaseURL}
 }
 
 type smhiDataPoint struct {
@@ -79,102 +91,107 @@ var (
 		26: {iface.CodeLightSnow, "Moderate snowfall"},
 		27: {iface.CodeHeavySnow, "Heavy snowfall"},
 	}
+
+	// see the pcat parameter in the SMHI api documentation
+	precipCategories = map[int]iface.PrecipType{
+		0: iface.PrecipNone,
+		1: iface.PrecipSnow,
+		2: iface.PrecipSleet,
+		3: iface.PrecipRain,
+		4: iface.PrecipDrizzle,
+		5: iface.PrecipFreezingRain,
+		6: iface.PrecipFreezingRain,
+	}
 )
 
 func (c *smhiConfig) Setup() {
+	flag.StringVar(&c.baseURL, "smhi-base-url", "", "smhi backend: the `URL` of a mirror or test server to use instead of opendata-download-metfcst.smhi.se")
 }
 
-func (c *smhiConfig) fetch(url string) (*smhiResponse, error) {
-	resp, err := http.Get(url)
+func (c *smhiConfig) fetch(ctx context.Context, url string) (*smhiResponse, error) {
+	body, err := httpclient.Get(ctx, url)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to get (%s): %v", url, err)
-	} else if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		quip := ""
 		if string(body) == "Requested point is out of bounds" {
-			quip = "\nPlease note that SMHI only service the nordic countries."
+			return nil, fmt.Errorf("%w: %s\nPlease note that SMHI only service the nordic countries.", err, body)
 		}
-		return nil, fmt.Errorf("Unable to get (%s): http status %d, %s%s", url, resp.StatusCode, body, quip)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("Unable to read response body (%s): %v", url, err)
+		return nil, err
 	}
 
 	var response smhiResponse
 	err = json.Unmarshal(body, &response)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to parse response (%s): %v", url, err)
+		return nil, fmt.Errorf("%w: unable to parse response (%s): %v", iface.ErrParse, url, err)
+	}
+	if len(response.TimeSeries) == 0 || len(response.Geometry.Coordinates) == 0 {
+		return nil, fmt.Errorf("%w: no forecast in response (%s)", iface.ErrParse, url)
 	}
 	return &response, nil
 
 }
 
-func (c *smhiConfig) Fetch(location string, numDays int) (ret iface.Data) {
-	if matched, err := regexp.MatchString(`^-?[0-9]*(\.[0-9]+)?,-?[0-9]*(\.[0-9]+)?$`, location); !matched || err != nil {
-		log.Fatalf("Error: The smhi backend only supports latitude,longitude pairs as location.\nInstead of `%s` try `59.329,18.068` for example to get a forecast for Stockholm.", location)
+func (c *smhiConfig) SupportedLocations() iface.LocationKind {
+	return iface.LocationCoords
+}
+
+func (c *smhiConfig) Fetch(ctx context.Context, location iface.Location, numDays int) (ret iface.Data, err error) {
+	if err := location.Check("smhi", c.SupportedLocations()); err != nil {
+		return ret, err
 	}
 
-	s := strings.Split(location, ",")
-	requestUrl := fmt.Sprintf(smhiWuri, s[1], s[0])
+	lat, lon := location.Coords()
+	requestUrl, err := rebase(fmt.Sprintf(smhiWuri, lon, lat), c.baseURL)
+	if err != nil {
+		return ret, err
+	}
 
-	resp, err := c.fetch(requestUrl)
+	resp, err := c.fetch(ctx, requestUrl)
 	if err != nil {
-		log.Fatalf("Failed to fetch weather data: %v\n", err)
+		return ret, err
 	}
 
-	ret.Current = c.parseCurrent(resp)
-	ret.Forecast = c.parseForecast(resp, numDays)
+	if ret.Current, err = c.parseCurrent(resp); err != nil {
+		return ret, err
+	}
+	// the licence of the smhi open data requires to credit the provider
+	ret.Source = "SMHI"
 	coordinates := resp.Geometry.Coordinates
 	ret.GeoLoc = &iface.LatLon{Latitude: coordinates[0][1], Longitude: coordinates[0][0]}
-	ret.Location = location + " (Forecast provided by SMHI)"
-	return ret
+	// smhi does not report the time zone, the client looks it up and groups
+	// the days in it
+	if ret.Forecast, err = c.parseForecast(resp, numDays, time.UTC); err != nil {
+		return ret, err
+	}
+	return ret, nil
 }
-func (c *smhiConfig) parseForecast(response *smhiResponse, numDays int) (days []iface.Day) {
+
+// parseForecast groups the predictions into numDays days of the time zone loc.
+// The predictions are resampled to one hour, because their interval grows
+// from one to twelve hours towards the end of the forecast.
+func (c *smhiConfig) parseForecast(response *smhiResponse, numDays int, loc *time.Location) (days []iface.Day, err error) {
 	if numDays > 10 {
 		numDays = 10
 	}
 
-	var currentTime time.Time = time.Now()
-	var dayCount = 0
-
-	var day iface.Day
-	day.Date = time.Now()
+	var slots []iface.Cond
 	for _, prediction := range response.TimeSeries {
-		if dayCount == numDays {
-			break
-		}
-
-		ts, err := time.Parse(time.RFC3339, prediction.ValidTime)
+		slot, err := c.parsePrediction(prediction)
 		if err != nil {
-			log.Fatalf("Failed to parse timestamp: %v\n", err)
+			return nil, err
 		}
-
-		if ts.Day() != currentTime.Day() {
-			dayCount += 1
-			currentTime = ts
-			days = append(days, day)
-			day = iface.Day{Date: ts}
-		}
-		day.Slots = append(day.Slots, c.parsePrediction(prediction))
+		slot.Time = slot.Time.In(loc)
+		slots = append(slots, slot)
 	}
-
-	return days
+	return iface.GroupDays(iface.Resample(slots, time.Hour), loc, numDays), nil
 }
 
-func (c *smhiConfig) parseCurrent(forecast *smhiResponse) (cnd iface.Cond) {
-	if len(forecast.TimeSeries) < 0 {
-		log.Fatalln("Failed to fetch weather data: No Forecast in response")
-	}
+func (c *smhiConfig) parseCurrent(forecast *smhiResponse) (cnd iface.Cond, err error) {
 	var currentPrediction *smhiTimeSeries = forecast.TimeSeries[0]
-	var currentTime time.Time = time.Now().UTC()
+	var currentTime time.Time = now().UTC()
 
 	for _, prediction := range forecast.TimeSeries {
 		ts, err := time.Parse(time.RFC3339, prediction.ValidTime)
 		if err != nil {
-			log.Fatalf("Failed to parse timestamp: %v\n", err)
+			return cnd, fmt.Errorf("%w: failed to parse timestamp: %v", iface.ErrParse, err)
 		}
 
 		if ts.After(currentTime) {
@@ -184,46 +201,62 @@ func (c *smhiConfig) parseCurrent(forecast *smhiResponse) (cnd iface.Cond) {
 	return c.parsePrediction(currentPrediction)
 }
 
-func (c *smhiConfig) parsePrediction(prediction *smhiTimeSeries) (cnd iface.Cond) {
+func (c *smhiConfig) parsePrediction(prediction *smhiTimeSeries) (cnd iface.Cond, err error) {
 	ts, err := time.Parse(time.RFC3339, prediction.ValidTime)
 	if err != nil {
-		log.Fatalf("Failed to parse timestamp: %v\n", err)
+		return cnd, fmt.Errorf("%w: failed to parse timestamp: %v", iface.ErrParse, err)
 	}
 	cnd.Time = ts
 
 	for _, param := range prediction.Parameters {
+		if len(param.Values) == 0 {
+			continue
+		}
+		v, ok := param.Values[0].(float64)
+		if !ok {
+			return cnd, fmt.Errorf("%w: unexpected value %v for parameter %s", iface.ErrParse, param.Values[0], param.Name)
+		}
+
 		switch param.Name {
 		case "pmean":
-			precip := float32(param.Values[0].(float64) / 1000) // Convert mm/h to m/h
+			precip := float32(v / 1000) // Convert mm/h to m/h
 			cnd.PrecipM = &precip
 		case "vis":
-			vis := float32(param.Values[0].(float64) * 1000) // Convert km to m
+			vis := float32(v * 1000) // Convert km to m
 			cnd.VisibleDistM = &vis
 		case "t":
-			temp := float32(param.Values[0].(float64))
+			temp := float32(v)
 			cnd.TempC = &temp
 		case "Wsymb2":
-			condition := weatherConditions[int(param.Values[0].(float64))]
+			condition := weatherConditions[int(v)]
 			cnd.Code = condition.WeatherCode
 			cnd.Desc = condition.Description
 		case "ws":
-			windSpeed := float32(param.Values[0].(float64) * 3.6) // convert m/s to km/h
+			windSpeed := float32(v * 3.6) // convert m/s to km/h
 			cnd.WindspeedKmph = &windSpeed
 		case "gust":
-			gustSpeed := float32(param.Values[0].(float64) * 3.6) // convert m/s to km/h
+			gustSpeed := float32(v * 3.6) // convert m/s to km/h
 			cnd.WindGustKmph = &gustSpeed
 		case "wd":
-			val := int(param.Values[0].(float64))
+			val := int(v)
 			cnd.WinddirDegree = &val
 		case "r":
-			val := int(param.Values[0].(float64))
+			val := int(v)
 			cnd.Humidity = &val
+		case "msl":
+			pressure := float32(v)
+			cnd.PressureHPa = &pressure
+		case "tcc_mean":
+			cover := int(v*100/8 + 0.5) // convert octas to percent
+			cnd.CloudCoverPercent = &cover
+		case "pcat":
+			cnd.PrecipType = precipCategories[int(v)]
 		default:
 			continue
 		}
 	}
 
-	return cnd
+	return cnd, nil
 }
 
 func init() {
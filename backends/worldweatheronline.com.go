@@ -2,11 +2,11 @@ package backends
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
-	"io"
+	"fmt"
 	"log"
-	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -16,6 +16,7 @@ import (
 	// v1.4.2 or later is in debian stable and the latest Ubuntu LTS release.
 	_ "crypto/sha512"
 
+	"github.com/schachmat/wego/httpclient"
 	"github.com/schachmat/wego/iface"
 )
 
@@ -32,6 +33,10 @@ type wwoCond struct {
 	WindGustKmph  *float32                 `json:",string"`
 	WinddirDegree *int                     `json:"winddirDegree,string"`
 	WindspeedKmph *float32                 `json:"windspeedKmph,string"`
+	PressureHPa   *float32                 `json:"pressure,string"`
+	DewPointC     *float32                 `json:",string"`
+	CloudCover    *int                     `json:"cloudcover,string"`
+	UVIndex       *float32                 `json:"uvIndex,string"`
 }
 
 type wwoDay struct {
@@ -41,8 +46,10 @@ type wwoDay struct {
 		Sunrise  string
 		Sunset   string
 	}
-	Date   string
-	Hourly []wwoCond
+	Date     string
+	Hourly   []wwoCond
+	MaxTempC *float32 `json:"maxtempC,string"`
+	MinTempC *float32 `json:"mintempC,string"`
 }
 
 type wwoResponse struct {
@@ -53,7 +60,11 @@ type wwoResponse struct {
 			Query string `json:"query"`
 			Type  string `json:"type"`
 		} `json:"request"`
-		Days []wwoDay `json:"weather"`
+		Days     []wwoDay `json:"weather"`
+		TimeZone []struct {
+			UTCOffset float32 `json:"utcOffset,string"`
+			Zone      string  `json:"zone"`
+		} `json:"time_zone"`
 	} `json:"data"`
 }
 
@@ -70,6 +81,33 @@ type wwoConfig struct {
 	apiKey   string
 	language string
 	debug    bool
+	baseURL  string
+}
+
+// WorldWeatherOnlineOptions configures the worldweatheronline backend when it is
+// used as a library.
+type WorldWeatherOnlineOptions struct {
+	// APIKey is the worldweatheronline.com api key to use.
+	APIKey string
+
+	// Lang is the language of the weather descriptions. Defaults to "en".
+	Lang string
+
+	// Debug prints raw requests and responses.
+	Debug bool
+
+	// BaseURL replaces the scheme and host of the api, e.g. to use a mirror or
+	// a test server.
+	BaseURL string
+}
+
+// New returns a worldweatheronline backend configured by o.
+func (o WorldWeatherOnlineOptions) New() iface.Backend {
+	c := &wwoConfig{apiKey: o.APIKey, language: o.Lang, debug: o.Debug, baseURL: o.BaseURL}
+	if c.language == "" {
+		c.language = "en"
+	}
+	return c
 }
 
 const (
@@ -77,6 +115,29 @@ const (
 	wwoWuri = "https://api.worldweatheronline.com/free/v2/weather.ashx?"
 )
 
+// wwoPrecipTypes derives the kind of precipitation from the weather code, as
+// worldweatheronline does not report it separately.
+var wwoPrecipTypes = map[iface.WeatherCode]iface.PrecipType{
+	iface.CodeCloudy:              iface.PrecipNone,
+	iface.CodeFog:                 iface.PrecipNone,
+	iface.CodeHeavyRain:           iface.PrecipRain,
+	iface.CodeHeavyShowers:        iface.PrecipRain,
+	iface.CodeHeavySnow:           iface.PrecipSnow,
+	iface.CodeHeavySnowShowers:    iface.PrecipSnow,
+	iface.CodeLightRain:           iface.PrecipRain,
+	iface.CodeLightShowers:        iface.PrecipRain,
+	iface.CodeLightSleet:          iface.PrecipSleet,
+	iface.CodeLightSleetShowers:   iface.PrecipSleet,
+	iface.CodeLightSnow:           iface.PrecipSnow,
+	iface.CodeLightSnowShowers:    iface.PrecipSnow,
+	iface.CodePartlyCloudy:        iface.PrecipNone,
+	iface.CodeSunny:               iface.PrecipNone,
+	iface.CodeThunderyHeavyRain:   iface.PrecipRain,
+	iface.CodeThunderyShowers:     iface.PrecipRain,
+	iface.CodeThunderySnowShowers: iface.PrecipSnow,
+	iface.CodeVeryCloudy:          iface.PrecipNone,
+}
+
 func wwoParseCond(cond wwoCond, date time.Time) (ret iface.Cond) {
 	ret.ChanceOfRainPercent = cond.TmpCor
 
@@ -133,6 +194,7 @@ func wwoParseCond(cond wwoCond, date time.Time) (ret iface.Cond) {
 	ret.Code = iface.CodeUnknown
 	if val, ok := codemap[cond.TmpCode]; ok {
 		ret.Code = val
+		ret.PrecipType = wwoPrecipTypes[val]
 	}
 
 	if cond.TmpDesc != nil && len(cond.TmpDesc) > 0 {
@@ -154,7 +216,7 @@ func wwoParseCond(cond wwoCond, date time.Time) (ret iface.Cond) {
 	if cond.TmpTime != nil {
 		year, month, day := date.Date()
 		hour, min := *cond.TmpTime/100, *cond.TmpTime%100
-		ret.Time = time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+		ret.Time = time.Date(year, month, day, hour, min, 0, 0, date.Location())
 	}
 
 	if cond.VisibleDistKM != nil {
@@ -170,14 +232,19 @@ func wwoParseCond(cond wwoCond, date time.Time) (ret iface.Cond) {
 	ret.WindspeedKmph = cond.WindspeedKmph
 	ret.WindGustKmph = cond.WindGustKmph
 
+	ret.PressureHPa = cond.PressureHPa
+	ret.DewPointC = cond.DewPointC
+	ret.CloudCoverPercent = cond.CloudCover
+	ret.UVIndex = cond.UVIndex
+
 	return
 }
 
-func wwoParseDay(day wwoDay, index int) (ret iface.Day) {
+func wwoParseDay(day wwoDay, index int, loc *time.Location) (ret iface.Day) {
 	//TODO: Astronomy
 
-	ret.Date = time.Now().Add(time.Hour * 24 * time.Duration(index))
-	date, err := time.Parse("2006-01-02", day.Date)
+	ret.Date = now().In(loc).Add(time.Hour * 24 * time.Duration(index))
+	date, err := time.ParseInLocation("2006-01-02", day.Date, loc)
 	if err == nil {
 		ret.Date = date
 	}
@@ -187,6 +254,8 @@ func wwoParseDay(day wwoDay, index int) (ret iface.Day) {
 			ret.Slots = append(ret.Slots, wwoParseCond(slot, date))
 		}
 	}
+	ret.Summary.MinTempC = day.MinTempC
+	ret.Summary.MaxTempC = day.MaxTempC
 
 	return
 }
@@ -251,28 +320,16 @@ func (c *wwoConfig) Setup() {
 	flag.StringVar(&c.apiKey, "wwo-api-key", "", "worldweatheronline backend: the api `KEY` to use")
 	flag.StringVar(&c.language, "wwo-lang", "en", "worldweatheronline backend: the `LANGUAGE` to request from worldweatheronline")
 	flag.BoolVar(&c.debug, "wwo-debug", false, "worldweatheronline backend: print raw requests and responses")
+	flag.StringVar(&c.baseURL, "wwo-base-url", "", "worldweatheronline backend: the `URL` of a mirror or test server to use instead of api.worldweatheronline.com")
 }
 
-func (c *wwoConfig) getCoordinatesFromAPI(queryParams []string, res chan *iface.LatLon) {
+func (c *wwoConfig) getCoordinatesFromAPI(ctx context.Context, requri string, res chan *iface.LatLon) {
 	var coordResp wwoCoordinateResp
-	requri := wwoSuri + strings.Join(queryParams, "&")
-	hres, err := http.Get(requri)
+	body, err := httpclient.Get(ctx, requri)
 	if err != nil {
 		log.Println("Unable to fetch geo location:", err)
 		res <- nil
 		return
-	} else if hres.StatusCode != 200 {
-		log.Println("Unable to fetch geo location: http status", hres.StatusCode)
-		res <- nil
-		return
-	}
-	defer hres.Body.Close()
-
-	body, err := io.ReadAll(hres.Body)
-	if err != nil {
-		log.Println("Unable to read geo location data:", err)
-		res <- nil
-		return
 	}
 
 	if c.debug {
@@ -296,42 +353,51 @@ func (c *wwoConfig) getCoordinatesFromAPI(queryParams []string, res chan *iface.
 	res <- &iface.LatLon{Latitude: *r[0].Latitude, Longitude: *r[0].Longitude}
 }
 
-func (c *wwoConfig) Fetch(loc string, numdays int) iface.Data {
+// Language returns the language the weather descriptions are requested in.
+func (c *wwoConfig) Language() string {
+	return c.language
+}
+
+func (c *wwoConfig) SupportedLocations() iface.LocationKind {
+	return iface.LocationCoords | iface.LocationName | iface.LocationPostal
+}
+
+func (c *wwoConfig) Fetch(ctx context.Context, loc iface.Location, numdays int) (iface.Data, error) {
 	var params []string
 	var resp wwoResponse
 	var ret iface.Data
-	coordChan := make(chan *iface.LatLon)
+	coordChan := make(chan *iface.LatLon, 1)
 
 	if len(c.apiKey) == 0 {
-		log.Fatal("No API key specified. Setup instructions are in the README.")
+		return ret, fmt.Errorf("%w: no API key specified. Setup instructions are in the README", iface.ErrAuth)
 	}
-	params = append(params, "key="+c.apiKey)
-
-	if len(loc) > 0 {
-		params = append(params, "q="+url.QueryEscape(loc))
+	if err := loc.Check("worldweatheronline", c.SupportedLocations()); err != nil {
+		return ret, err
 	}
+	params = append(params, "key="+c.apiKey)
+	params = append(params, "q="+url.QueryEscape(loc.String()))
 	params = append(params, "format=json")
 	params = append(params, "num_of_days="+strconv.Itoa(numdays))
 	params = append(params, "tp=3")
+	params = append(params, "tz=yes")
 
-	go c.getCoordinatesFromAPI(params, coordChan)
+	suri, err := rebase(wwoSuri+strings.Join(params, "&"), c.baseURL)
+	if err != nil {
+		return ret, err
+	}
+	go c.getCoordinatesFromAPI(ctx, suri, coordChan)
 
 	if c.language != "" {
 		params = append(params, "lang="+c.language)
 	}
-	requri := wwoWuri + strings.Join(params, "&")
-
-	res, err := http.Get(requri)
+	requri, err := rebase(wwoWuri+strings.Join(params, "&"), c.baseURL)
 	if err != nil {
-		log.Fatal("Unable to get weather data: ", err)
-	} else if res.StatusCode != 200 {
-		log.Fatal("Unable to get weather data: http status ", res.StatusCode)
+		return ret, err
 	}
-	defer res.Body.Close()
 
-	body, err := io.ReadAll(res.Body)
+	body, err := httpclient.Get(ctx, requri)
 	if err != nil {
-		log.Fatal(err)
+		return ret, err
 	}
 
 	if c.debug {
@@ -340,36 +406,44 @@ func (c *wwoConfig) Fetch(loc string, numdays int) iface.Data {
 	}
 
 	if c.language == "" {
-		if err = json.Unmarshal(body, &resp); err != nil {
-			log.Println(err)
-		}
+		err = json.Unmarshal(body, &resp)
 	} else {
-		if err = wwoUnmarshalLang(body, &resp, c.language); err != nil {
-			log.Println(err)
-		}
+		err = wwoUnmarshalLang(body, &resp, c.language)
+	}
+	if err != nil {
+		return ret, fmt.Errorf("%w: %v", iface.ErrParse, err)
 	}
 
 	if resp.Data.Req == nil || len(resp.Data.Req) < 1 {
 		if resp.Data.Err != nil && len(resp.Data.Err) >= 1 {
-			log.Fatal(resp.Data.Err[0].Msg)
+			return ret, fmt.Errorf("%w: %s", iface.ErrUnknownLocation, resp.Data.Err[0].Msg)
 		}
-		log.Fatal("Malformed response.")
+		return ret, fmt.Errorf("%w: no request information in response", iface.ErrParse)
 	}
 
 	ret.Location = resp.Data.Req[0].Type + ": " + resp.Data.Req[0].Query
 	ret.GeoLoc = <-coordChan
+	// without the time zone of the response the client looks it up and
+	// regroups the days, which are taken as UTC until then
+	tz := time.UTC
+	if zone := resp.Data.TimeZone; len(zone) > 0 {
+		if ret.TimeZone, err = iface.LoadTimeZone(zone[0].Zone); err != nil {
+			ret.TimeZone = iface.FixedTimeZone(int(zone[0].UTCOffset * 3600))
+		}
+		tz = ret.TimeZone.Location
+	}
 
 	if resp.Data.CurCond != nil && len(resp.Data.CurCond) > 0 {
-		ret.Current = wwoParseCond(resp.Data.CurCond[0], time.Now())
+		ret.Current = wwoParseCond(resp.Data.CurCond[0], now().In(tz))
 	}
 
 	if resp.Data.Days != nil && numdays > 0 {
 		for i, day := range resp.Data.Days {
-			ret.Forecast = append(ret.Forecast, wwoParseDay(day, i))
+			ret.Forecast = append(ret.Forecast, wwoParseDay(day, i, tz))
 		}
 	}
 
-	return ret
+	return ret, nil
 }
 
 func init() {
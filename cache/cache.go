@@ -0,0 +1,196 @@
+// Package cache stores the weather data fetched by wego backends on disk, so
+// repeated runs within a short time do not query the weather provider again.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// DefaultTTL is the time for which cached weather data is used by the command
+// line unless another TTL is configured.
+const DefaultTTL = 10 * time.Minute
+
+// Dir returns the default cache directory, which is wego inside
+// $XDG_CACHE_HOME or the cache directory of the operating system.
+func Dir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "wego"), nil
+}
+
+// Clear removes dir and all data cached in it.
+func Clear(dir string) error {
+	return os.RemoveAll(dir)
+}
+
+// Options configures the cache.
+type Options struct {
+	// Dir is the directory the data is stored in. It defaults to Dir().
+	Dir string
+
+	// TTL is the time for which cached data is used. Zero always queries the
+	// backend and only uses the cached data if the backend cannot be reached.
+	TTL time.Duration
+
+	// Offline never queries the backend and answers with the last cached
+	// data, no matter how old it is.
+	Offline bool
+}
+
+// languager is implemented by backends which request the weather descriptions
+// in a configurable language.
+type languager interface {
+	Language() string
+}
+
+// memberer is implemented by backends which query other backends, like the
+// fallback and ensemble ones.
+type memberer interface {
+	Members() []string
+}
+
+type cachedBackend struct {
+	iface.Backend
+	name    string
+	dir     string
+	ttl     time.Duration
+	offline bool
+}
+
+// entry is the file format of the cache.
+type entry struct {
+	Key     string
+	Fetched time.Time
+	Data    iface.Data
+}
+
+// New returns a backend which answers from the cache if the data for the same
+// location, number of days and language was fetched from be less than the TTL
+// ago. Otherwise the data is fetched from be and stored in the cache. If be
+// cannot be reached, the last cached data is returned marked as stale. name is
+// the name of be and part of the cache key.
+func (o Options) New(name string, be iface.Backend) iface.Backend {
+	c := &cachedBackend{Backend: be, name: name, dir: o.Dir, ttl: o.TTL, offline: o.Offline}
+	if c.dir == "" {
+		c.dir, _ = Dir()
+	}
+	return c
+}
+
+// Setup does nothing. The flags of the wrapped backend are set up by the
+// backend itself.
+func (c *cachedBackend) Setup() {
+}
+
+func (c *cachedBackend) Fetch(ctx context.Context, loc iface.Location, numdays int) (iface.Data, error) {
+	key := c.key(loc, numdays)
+	path := filepath.Join(c.dir, "data", hash(key)+".json")
+
+	var e entry
+	cached := loadJSON(path, &e) == nil && e.Key == key
+	if cached && time.Since(e.Fetched) < c.ttl {
+		return e.Data, nil
+	}
+	if c.offline {
+		if !cached {
+			return iface.Data{}, fmt.Errorf("%w: offline and no cached data for %s", iface.ErrUpstream, loc)
+		}
+		return stale(e), nil
+	}
+
+	data, err := c.Backend.Fetch(ctx, loc, numdays)
+	if err != nil {
+		if cached && errors.Is(err, iface.ErrUpstream) {
+			return stale(e), nil
+		}
+		return data, err
+	}
+	data.Fetched = time.Now()
+	// a broken cache must not break the forecast, so write errors are ignored
+	_ = store(path, entry{Key: key, Fetched: time.Now(), Data: data})
+	return data, nil
+}
+
+// stale returns the data of e marked as stale and without the forecast which
+// already lies in the past.
+func stale(e entry) iface.Data {
+	data := e.Data.DropPast(time.Now())
+	data.Fetched = e.Fetched
+	data.Stale = true
+	return data
+}
+
+// key returns the cache key of a request for numdays days at loc.
+func (c *cachedBackend) key(loc iface.Location, numdays int) string {
+	lang := ""
+	if l, ok := c.Backend.(languager); ok {
+		lang = l.Language()
+	}
+	var members []string
+	if m, ok := c.Backend.(memberer); ok {
+		members = m.Members()
+	}
+	return fmt.Sprintf("%s|%s|%d|%s|%s", c.name, normalize(loc), numdays, lang, strings.Join(members, ","))
+}
+
+// normalize returns loc in a canonical form, so that the same location written
+// differently hits the same cache entry.
+func normalize(loc iface.Location) string {
+	if loc.Kind == iface.LocationCoords {
+		return loc.String()
+	}
+	return strings.ToLower(strings.Join(strings.Fields(loc.String()), " "))
+}
+
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadJSON reads the json file at path into v.
+func loadJSON(path string, v interface{}) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// store writes v as json to path. The file is written to a temporary file
+// first, so concurrent readers never see a partially written file.
+func store(path string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
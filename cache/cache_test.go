@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// fakeBackend counts the fetches and answers with err if it is set.
+type fakeBackend struct {
+	calls int
+	err   error
+	lang  string
+}
+
+func (f *fakeBackend) Setup() {}
+
+func (f *fakeBackend) SupportedLocations() iface.LocationKind {
+	return iface.LocationAny
+}
+
+func (f *fakeBackend) Fetch(ctx context.Context, loc iface.Location, numdays int) (iface.Data, error) {
+	f.calls++
+	if f.err != nil {
+		return iface.Data{}, f.err
+	}
+	return iface.Data{Location: fmt.Sprintf("%s #%d", loc, f.calls)}, nil
+}
+
+func (f *fakeBackend) Language() string {
+	return f.lang
+}
+
+func mustParse(t *testing.T, s string) iface.Location {
+	t.Helper()
+	loc, err := iface.ParseLocation(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestKey(t *testing.T) {
+	c := Options{Dir: t.TempDir()}.New("fake", &fakeBackend{lang: "de"}).(*cachedBackend)
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"New York", "new  york", true},
+		{" Berlin ", "BERLIN", true},
+		{"59.3290,18.0680", "59.329, 18.068", true},
+		{"10001,us", "10001, US", true},
+		{"Berlin", "Bern", false},
+		{"59.329,18.068", "59.33,18.068", false},
+	}
+
+	for _, test := range tests {
+		a, b := c.key(mustParse(t, test.a), 3), c.key(mustParse(t, test.b), 3)
+		if (a == b) != test.same {
+			t.Errorf("key(%q) = %q, key(%q) = %q, want same %v", test.a, a, test.b, b, test.same)
+		}
+	}
+
+	loc := mustParse(t, "Berlin")
+	if c.key(loc, 3) == c.key(loc, 4) {
+		t.Errorf("same key for different numbers of days")
+	}
+	other := Options{Dir: t.TempDir()}.New("fake", &fakeBackend{lang: "en"}).(*cachedBackend)
+	if c.key(loc, 3) == other.key(loc, 3) {
+		t.Errorf("same key for different languages")
+	}
+}
+
+func TestTTL(t *testing.T) {
+	tests := []struct {
+		name  string
+		ttl   time.Duration
+		age   time.Duration
+		calls int
+	}{
+		{"fresh", time.Hour, time.Minute, 0},
+		{"expired", time.Hour, 2 * time.Hour, 1},
+		{"default fresh", DefaultTTL, DefaultTTL / 2, 0},
+		{"default expired", DefaultTTL, DefaultTTL * 2, 1},
+		{"always refetch", 0, 0, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			be := &fakeBackend{}
+			c := Options{Dir: t.TempDir(), TTL: test.ttl}.New("fake", be).(*cachedBackend)
+			loc := mustParse(t, "Berlin")
+			key := c.key(loc, 1)
+			cached := iface.Data{Location: "cached"}
+			if err := store(filepath.Join(c.dir, "data", hash(key)+".json"), entry{Key: key, Fetched: time.Now().Add(-test.age), Data: cached}); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := c.Fetch(context.Background(), loc, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if be.calls != test.calls {
+				t.Errorf("backend queried %d times, want %d", be.calls, test.calls)
+			}
+			if fromCache := data.Location == "cached"; fromCache != (test.calls == 0) {
+				t.Errorf("got data of %q", data.Location)
+			}
+		})
+	}
+}
+
+func TestStoresAndReuses(t *testing.T) {
+	be := &fakeBackend{}
+	c := Options{Dir: t.TempDir(), TTL: DefaultTTL}.New("fake", be)
+	for i := 0; i < 3; i++ {
+		data, err := c.Fetch(context.Background(), mustParse(t, "Berlin"), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data.Location != "Berlin #1" || data.Fetched.IsZero() {
+			t.Errorf("fetch %d: got %q fetched at %v", i, data.Location, data.Fetched)
+		}
+	}
+	if be.calls != 1 {
+		t.Errorf("backend queried %d times, want 1", be.calls)
+	}
+}
+
+func TestStale(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		offline bool
+		cached  bool
+		wantErr error
+	}{
+		{"unreachable", iface.ErrUpstream, false, true, nil},
+		{"unreachable without cache", iface.ErrUpstream, false, false, iface.ErrUpstream},
+		{"rejected key", iface.ErrAuth, false, true, iface.ErrAuth},
+		{"offline", nil, true, true, nil},
+		{"offline without cache", nil, true, false, iface.ErrUpstream},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			be := &fakeBackend{err: test.err}
+			c := Options{Dir: t.TempDir(), Offline: test.offline}.New("fake", be).(*cachedBackend)
+			loc := mustParse(t, "Berlin")
+			key := c.key(loc, 1)
+			fetched := time.Now().Add(-48 * time.Hour)
+			if test.cached {
+				day := iface.Day{Date: fetched.Truncate(24 * time.Hour)}
+				data := iface.Data{Location: "cached", Forecast: []iface.Day{day}}
+				if err := store(filepath.Join(c.dir, "data", hash(key)+".json"), entry{Key: key, Fetched: fetched, Data: data}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			data, err := c.Fetch(context.Background(), loc, 1)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("got error %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !data.Stale || data.Location != "cached" || !data.Fetched.Equal(fetched) {
+				t.Errorf("got %q stale %v fetched %v, want the stale cached data", data.Location, data.Stale, data.Fetched)
+			}
+			if len(data.Forecast) != 0 {
+				t.Errorf("past days of stale data kept")
+			}
+			if test.offline && be.calls != 0 {
+				t.Errorf("backend queried while offline")
+			}
+		})
+	}
+}
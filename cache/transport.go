@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+)
+
+// Transport is an http.RoundTripper which remembers the ETag and
+// Last-Modified headers of successful GET responses. When the same URL is
+// requested again, it sends them as If-None-Match and If-Modified-Since and
+// answers a 304 Not Modified response with the stored body.
+type Transport struct {
+	// Dir is the directory the responses are stored in. It defaults to
+	// Dir().
+	Dir string
+
+	// Base is the RoundTripper doing the actual requests. It defaults to
+	// http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+// response is the file format of a stored response.
+type response struct {
+	ETag         string
+	LastModified string
+	Header       http.Header
+	Body         []byte
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	dir := t.Dir
+	if dir == "" {
+		dir, _ = Dir()
+	}
+	if req.Method != http.MethodGet || dir == "" {
+		return base.RoundTrip(req)
+	}
+
+	path := filepath.Join(dir, "http", hash(req.URL.String())+".json")
+	var stored response
+	if err := loadJSON(path, &stored); err == nil {
+		req = req.Clone(req.Context())
+		if stored.ETag != "" {
+			req.Header.Set("If-None-Match", stored.ETag)
+		}
+		if stored.LastModified != "" {
+			req.Header.Set("If-Modified-Since", stored.LastModified)
+		}
+	}
+
+	res, err := base.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && stored.Body != nil:
+		res.Body.Close()
+		res.StatusCode = http.StatusOK
+		res.Status = strconv.Itoa(http.StatusOK) + " " + http.StatusText(http.StatusOK)
+		res.Header = stored.Header.Clone()
+		res.ContentLength = int64(len(stored.Body))
+		res.Body = io.NopCloser(bytes.NewReader(stored.Body))
+	case res.StatusCode == http.StatusOK && (res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != ""):
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		// a broken cache must not break the request, so write errors are ignored
+		_ = store(path, response{
+			ETag:         res.Header.Get("ETag"),
+			LastModified: res.Header.Get("Last-Modified"),
+			Header:       res.Header.Clone(),
+			Body:         body,
+		})
+	}
+	return res, nil
+}
@@ -3,9 +3,8 @@ package frontends
 import (
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"math"
-	"os"
 	"regexp"
 	"strings"
 	"time"
@@ -19,13 +18,43 @@ type aatConfig struct {
 	coords     bool
 	monochrome bool
 	compact    bool
+	extended   bool
+	aqiChina   bool
 
 	unit iface.UnitSystem
 }
 
+// AsciiArtTableOptions configures the ascii-art-table frontend when it is used
+// as a library.
+type AsciiArtTableOptions struct {
+	// Coords shows the geo coordinates next to the location.
+	Coords bool
+
+	// Monochrome strips all colors from the output.
+	Monochrome bool
+
+	// Compact hides the weather icons.
+	Compact bool
+
+	// Extended shows pressure, cloud cover, dew point, UV index and the kind of
+	// precipitation in additional rows.
+	Extended bool
+
+	// AQIChina shows the air quality index on the chinese instead of the US
+	// scale.
+	AQIChina bool
+}
+
+// New returns an ascii-art-table frontend configured by o.
+func (o AsciiArtTableOptions) New() iface.Frontend {
+	return &aatConfig{coords: o.Coords, monochrome: o.Monochrome, compact: o.Compact, extended: o.Extended, aqiChina: o.AQIChina}
+}
+
+// ansiEsc matches the color escape sequences written by the frontends.
+var ansiEsc = regexp.MustCompile("\033.*?m")
+
 // TODO: replace s parameter with printf interface?
 func aatPad(s string, mustLen int) (ret string) {
-	ansiEsc := regexp.MustCompile("\033.*?m")
 	ret = s
 	realLen := runewidth.StringWidth(ansiEsc.ReplaceAllLiteralString(s, ""))
 	delta := mustLen - realLen
@@ -44,29 +73,41 @@ func aatPad(s string, mustLen int) (ret string) {
 	return
 }
 
-func (c *aatConfig) formatTemp(cond iface.Cond) string {
-	color := func(temp float32) string {
-		colmap := []struct {
-			maxtemp float32
-			color   int
-		}{
-			{-15, 21}, {-12, 27}, {-9, 33}, {-6, 39}, {-3, 45},
-			{0, 51}, {2, 50}, {4, 49}, {6, 48}, {8, 47},
-			{10, 46}, {13, 82}, {16, 118}, {19, 154}, {22, 190},
-			{25, 226}, {28, 220}, {31, 214}, {34, 208}, {37, 202},
+// printLines writes each of lines to w, followed by a newline.
+func printLines(w io.Writer, lines []string) error {
+	for _, l := range lines {
+		if _, err := fmt.Fprintln(w, l); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		col := 196
-		for _, candidate := range colmap {
-			if temp < candidate.maxtemp {
-				col = candidate.color
-				break
-			}
+// colorTemp returns temp in the configured unit, colored by its value.
+func (c *aatConfig) colorTemp(temp float32) string {
+	colmap := []struct {
+		maxtemp float32
+		color   int
+	}{
+		{-15, 21}, {-12, 27}, {-9, 33}, {-6, 39}, {-3, 45},
+		{0, 51}, {2, 50}, {4, 49}, {6, 48}, {8, 47},
+		{10, 46}, {13, 82}, {16, 118}, {19, 154}, {22, 190},
+		{25, 226}, {28, 220}, {31, 214}, {34, 208}, {37, 202},
+	}
+
+	col := 196
+	for _, candidate := range colmap {
+		if temp < candidate.maxtemp {
+			col = candidate.color
+			break
 		}
-		t, _ := c.unit.Temp(temp)
-		return fmt.Sprintf("\033[38;5;%03dm%d\033[0m", col, int(t))
 	}
+	t, _ := c.unit.Temp(temp)
+	return fmt.Sprintf("\033[38;5;%03dm%d\033[0m", col, int(t))
+}
 
+func (c *aatConfig) formatTemp(cond iface.Cond) string {
+	color := c.colorTemp
 	_, u := c.unit.Temp(0.0)
 
 	if cond.TempC == nil {
@@ -74,6 +115,9 @@ func (c *aatConfig) formatTemp(cond iface.Cond) string {
 	}
 
 	t := *cond.TempC
+	if min, max, ok := tempSpread(cond, c.unit); ok {
+		return aatPad(fmt.Sprintf("%s (%s–%s) %s", color(t), color(min), color(max), u), 15)
+	}
 	if cond.FeelsLikeC != nil {
 		fl := *cond.FeelsLikeC
 		return aatPad(fmt.Sprintf("%s (%s) %s", color(t), color(fl), u), 15)
@@ -148,7 +192,277 @@ func (c *aatConfig) formatRain(cond iface.Cond) string {
 	return aatPad("", 15)
 }
 
-func (c *aatConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret []string) {
+// precipNames are the human readable names of the kinds of precipitation.
+var precipNames = map[iface.PrecipType]string{
+	iface.PrecipNone:         "none",
+	iface.PrecipRain:         "rain",
+	iface.PrecipDrizzle:      "drizzle",
+	iface.PrecipFreezingRain: "freezing rain",
+	iface.PrecipSleet:        "sleet",
+	iface.PrecipSnow:         "snow",
+	iface.PrecipHail:         "hail",
+}
+
+// severityNames are the human readable names of the alert severities.
+var severityNames = map[iface.AlertSeverity]string{
+	iface.SeverityUnknown:  "Alert",
+	iface.SeverityMinor:    "Minor",
+	iface.SeverityModerate: "Moderate",
+	iface.SeveritySevere:   "Severe",
+	iface.SeverityExtreme:  "Extreme",
+}
+
+// moonPhaseNames are the human readable names of the moon phases.
+var moonPhaseNames = map[iface.MoonPhase]string{
+	iface.MoonNew:            "New moon",
+	iface.MoonWaxingCrescent: "Waxing crescent",
+	iface.MoonFirstQuarter:   "First quarter",
+	iface.MoonWaxingGibbous:  "Waxing gibbous",
+	iface.MoonFull:           "Full moon",
+	iface.MoonWaningGibbous:  "Waning gibbous",
+	iface.MoonLastQuarter:    "Last quarter",
+	iface.MoonWaningCrescent: "Waning crescent",
+}
+
+// moonPhaseEmoji are the emoji of the moon phases as seen from the northern
+// hemisphere.
+var moonPhaseEmoji = map[iface.MoonPhase]string{
+	iface.MoonNew:            "🌑",
+	iface.MoonWaxingCrescent: "🌒",
+	iface.MoonFirstQuarter:   "🌓",
+	iface.MoonWaxingGibbous:  "🌔",
+	iface.MoonFull:           "🌕",
+	iface.MoonWaningGibbous:  "🌖",
+	iface.MoonLastQuarter:    "🌗",
+	iface.MoonWaningCrescent: "🌘",
+}
+
+// moonPhaseASCII draws the moon phases as seen from the northern hemisphere
+// with the lit part as '#'.
+var moonPhaseASCII = map[iface.MoonPhase]string{
+	iface.MoonNew:            "(    )",
+	iface.MoonWaxingCrescent: "(   #)",
+	iface.MoonFirstQuarter:   "(  ##)",
+	iface.MoonWaxingGibbous:  "( ###)",
+	iface.MoonFull:           "(####)",
+	iface.MoonWaningGibbous:  "(### )",
+	iface.MoonLastQuarter:    "(##  )",
+	iface.MoonWaningCrescent: "(#   )",
+}
+
+// timeOfDay returns the time elapsed since midnight of t in its location.
+func timeOfDay(t time.Time) time.Duration {
+	y, m, d := t.Date()
+	return t.Sub(time.Date(y, m, d, 0, 0, 0, 0, t.Location()))
+}
+
+// tempSpread returns the lowest and highest temperature the members of an
+// ensemble forecast for cond. ok is false if they agree when shown in unit or
+// cond is not the result of an ensemble.
+func tempSpread(cond iface.Cond, unit iface.UnitSystem) (min, max float32, ok bool) {
+	if cond.Spread == nil || cond.Spread.Min.TempC == nil || cond.Spread.Max.TempC == nil {
+		return 0, 0, false
+	}
+	min, max = *cond.Spread.Min.TempC, *cond.Spread.Max.TempC
+	lo, _ := unit.Temp(min)
+	hi, _ := unit.Temp(max)
+	return min, max, int(lo) != int(hi)
+}
+
+// alertPeriod describes the time span in which alert applies.
+func alertPeriod(alert iface.Alert) string {
+	const layout = "Mon 02. Jan 15:04"
+	switch {
+	case !alert.Onset.IsZero() && !alert.Expiry.IsZero():
+		return "from " + alert.Onset.Format(layout) + " until " + alert.Expiry.Format(layout)
+	case !alert.Onset.IsZero():
+		return "since " + alert.Onset.Format(layout)
+	case !alert.Expiry.IsZero():
+		return "until " + alert.Expiry.Format(layout)
+	}
+	return ""
+}
+
+// nowcastBars renders the precipitation of nowcast as a bar chart of width
+// characters and returns it with the maximum intensity in m/h. Each character
+// shows the highest intensity of the slots it covers.
+func nowcastBars(nowcast []iface.Cond, width int) (bars string, max float32) {
+	levels := []struct {
+		maxmm float32
+		bar   rune
+	}{
+		{0, ' '}, {0.1, '▁'}, {0.25, '▂'}, {0.5, '▃'}, {1, '▄'},
+		{2, '▅'}, {4, '▆'}, {8, '▇'},
+	}
+
+	n := len(nowcast)
+	ret := make([]rune, width)
+	for col := range ret {
+		var colMax float32
+		start, end := col*n/width, (col+1)*n/width
+		if end <= start {
+			end = start + 1
+		}
+		for _, slot := range nowcast[start:end] {
+			if slot.PrecipM != nil && *slot.PrecipM > colMax {
+				colMax = *slot.PrecipM
+			}
+		}
+		if colMax > max {
+			max = colMax
+		}
+		ret[col] = '█'
+		for _, l := range levels {
+			if colMax*1000 <= l.maxmm {
+				ret[col] = l.bar
+				break
+			}
+		}
+	}
+	return string(ret), max
+}
+
+// rows returns the number of lines used to show a single condition.
+func (c *aatConfig) rows() int {
+	if c.extended {
+		return 8
+	}
+	return 5
+}
+
+func (c *aatConfig) formatAtmosphere(cond iface.Cond) string {
+	var parts []string
+	if cond.PressureHPa != nil {
+		parts = append(parts, fmt.Sprintf("%d hPa", int(*cond.PressureHPa)))
+	}
+	if cond.CloudCoverPercent != nil {
+		parts = append(parts, fmt.Sprintf("☁ %d%%", *cond.CloudCoverPercent))
+	}
+	return aatPad(strings.Join(parts, " "), 15)
+}
+
+func (c *aatConfig) formatDewPointUV(cond iface.Cond) string {
+	color := func(uv float32) string {
+		col := 129
+		for _, candidate := range []struct {
+			maxuv float32
+			color int
+		}{{3, 46}, {6, 226}, {8, 208}, {11, 196}} {
+			if uv < candidate.maxuv {
+				col = candidate.color
+				break
+			}
+		}
+		return fmt.Sprintf("\033[38;5;%03dm%d\033[0m", col, int(uv))
+	}
+
+	var parts []string
+	if cond.DewPointC != nil {
+		t, u := c.unit.Temp(*cond.DewPointC)
+		parts = append(parts, fmt.Sprintf("dew %d%s", int(t), u))
+	}
+	if cond.UVIndex != nil {
+		parts = append(parts, "UV "+color(*cond.UVIndex))
+	}
+	return aatPad(strings.Join(parts, " "), 15)
+}
+
+func (c *aatConfig) formatPrecipType(cond iface.Cond) string {
+	name := precipNames[cond.PrecipType]
+	if cond.SnowfallM != nil && *cond.SnowfallM > 0 {
+		v, u := c.unit.Distance(*cond.SnowfallM)
+		return aatPad(fmt.Sprintf("❄ %.1f %s/h", v, u), 15)
+	}
+	return aatPad(name, 15)
+}
+
+func (c *aatConfig) formatAirQuality(aq *iface.AirQuality, details bool) string {
+	levels := []struct {
+		maxaqi int
+		color  int
+		us     string
+		china  string
+	}{
+		{50, 46, "Good", "Excellent"},
+		{100, 226, "Moderate", "Good"},
+		{150, 208, "Unhealthy for Sensitive Groups", "Lightly Polluted"},
+		{200, 196, "Unhealthy", "Moderately Polluted"},
+		{300, 129, "Very Unhealthy", "Heavily Polluted"},
+		{math.MaxInt32, 88, "Hazardous", "Severely Polluted"},
+	}
+
+	if aq == nil {
+		return ""
+	}
+	aqi, scale := aq.AQIUS, "US"
+	if (c.aqiChina && aq.AQIChina != nil) || aqi == nil {
+		aqi, scale = aq.AQIChina, "China"
+	}
+
+	var parts []string
+	if aqi != nil {
+		for _, l := range levels {
+			if *aqi <= l.maxaqi {
+				name := l.us
+				if scale == "China" {
+					name = l.china
+				}
+				parts = append(parts, fmt.Sprintf("AQI \033[38;5;%03d;1m%d %s\033[0m (%s)", l.color, *aqi, name, scale))
+				break
+			}
+		}
+	}
+	if details && aq.PM25 != nil {
+		parts = append(parts, fmt.Sprintf("PM2.5 %d µg/m³", int(*aq.PM25)))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (c *aatConfig) formatNowcast(nowcast []iface.Cond) string {
+	if len(nowcast) == 0 {
+		return ""
+	}
+	bars, max := nowcastBars(nowcast, 48)
+	span := nowcast[0].Time.Format("15:04") + "–" + nowcast[len(nowcast)-1].Time.Format("15:04")
+	if max == 0 {
+		return "No rain expected " + span
+	}
+	v, u := c.unit.Distance(max)
+	return fmt.Sprintf("Rain %s ▕\033[38;5;33m%s\033[0m▏ max %.1f %s/h", span, bars, v, u)
+}
+
+func (c *aatConfig) formatAlerts(alerts []iface.Alert) (ret []string) {
+	colors := map[iface.AlertSeverity]int{
+		iface.SeverityUnknown:  250,
+		iface.SeverityMinor:    39,
+		iface.SeverityModerate: 226,
+		iface.SeveritySevere:   208,
+		iface.SeverityExtreme:  196,
+	}
+	for _, a := range alerts {
+		head := fmt.Sprintf("\033[38;5;%03d;1;7m ⚠ %s: %s \033[0m", colors[a.Severity], severityNames[a.Severity], a.Title)
+		details := []string{}
+		if period := alertPeriod(a); period != "" {
+			details = append(details, period)
+		}
+		if a.Source != "" {
+			details = append(details, a.Source)
+		}
+		if len(details) > 0 {
+			head += " " + strings.Join(details, ", ")
+		}
+		ret = append(ret, head)
+		if a.Description != "" {
+			ret = append(ret, "   "+runewidth.Truncate(strings.Join(strings.Fields(a.Description), " "), 120, "…"))
+		}
+	}
+	if len(ret) > 0 {
+		ret = append(ret, "")
+	}
+	return ret
+}
+
+func (c *aatConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret []string, err error) {
 	codes := map[iface.WeatherCode][]string{
 		iface.CodeUnknown: {
 			"    .-.      ",
@@ -286,11 +600,13 @@ func (c *aatConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret
 	}
 
 	icon := make([]string, 5)
+	blank := ""
 	if !c.compact {
+		blank = "             "
 		var ok bool
 		icon, ok = codes[cond.Code]
 		if !ok {
-			log.Fatalln("aat-frontend: The following weather code has no icon:", cond.Code)
+			return nil, fmt.Errorf("aat-frontend: The following weather code has no icon: %d", cond.Code)
 		}
 	}
 
@@ -304,7 +620,12 @@ func (c *aatConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret
 	ret = append(ret, fmt.Sprintf("%v %v %v", cur[2], icon[2], c.formatWind(cond)))
 	ret = append(ret, fmt.Sprintf("%v %v %v", cur[3], icon[3], c.formatVisibility(cond)))
 	ret = append(ret, fmt.Sprintf("%v %v %v", cur[4], icon[4], c.formatRain(cond)))
-	return
+	if c.extended {
+		ret = append(ret, fmt.Sprintf("%v %v %v", cur[5], blank, c.formatAtmosphere(cond)))
+		ret = append(ret, fmt.Sprintf("%v %v %v", cur[6], blank, c.formatDewPointUV(cond)))
+		ret = append(ret, fmt.Sprintf("%v %v %v", cur[7], blank, c.formatPrecipType(cond)))
+	}
+	return ret, nil
 }
 
 func (c *aatConfig) formatGeo(coords *iface.LatLon) (ret string) {
@@ -325,33 +646,72 @@ func (c *aatConfig) formatGeo(coords *iface.LatLon) (ret string) {
 	return
 }
 
-func (c *aatConfig) printDay(day iface.Day) (ret []string) {
-	desiredTimesOfDay := []time.Duration{
-		8 * time.Hour,
-		12 * time.Hour,
-		19 * time.Hour,
-		23 * time.Hour,
+// formatMoon returns the moon phase of astro as ASCII art followed by its name
+// and illumination or an empty string if the phase is unknown.
+func (c *aatConfig) formatMoon(astro iface.Astro) string {
+	if astro.MoonPhase == iface.MoonPhaseUnknown {
+		return ""
 	}
-	ret = make([]string, 5)
-	for i := range ret {
-		ret[i] = "│"
+	glyph := moonPhaseASCII[astro.MoonPhase]
+	if !c.monochrome {
+		glyph = "\033[38;5;228m" + glyph + "\033[0m"
+	}
+	return fmt.Sprintf("%s %s %.0f%%", glyph, moonPhaseNames[astro.MoonPhase], astro.MoonIllumination)
+}
+
+// formatSummary returns the temperature range, precipitation sum and highest
+// chance of rain of the day summary s. Unknown values are left out.
+func (c *aatConfig) formatSummary(s iface.Summary) string {
+	var parts []string
+	if s.MinTempC != nil && s.MaxTempC != nil {
+		_, u := c.unit.Temp(0.0)
+		parts = append(parts, fmt.Sprintf("%s – %s %s", c.colorTemp(*s.MinTempC), c.colorTemp(*s.MaxTempC), u))
 	}
+	if s.PrecipSumM != nil {
+		v, u := c.unit.Distance(*s.PrecipSumM)
+		parts = append(parts, fmt.Sprintf("%.1f %s", v, u))
+	}
+	if s.MaxChanceOfRainPercent != nil {
+		parts = append(parts, fmt.Sprintf("%d%%", *s.MaxChanceOfRainPercent))
+	}
+	return strings.Join(parts, "  ")
+}
 
-	// save our selected elements from day.Slots in this array
+// desiredTimesOfDay are the times of the morning, noon, evening and night
+// columns of a day.
+var desiredTimesOfDay = []time.Duration{
+	8 * time.Hour,
+	12 * time.Hour,
+	19 * time.Hour,
+	23 * time.Hour,
+}
+
+// pickSlots returns the slots which fit the desiredTimesOfDay best. Columns
+// without any slot have a zero Time.
+func pickSlots(slots []iface.Cond) []iface.Cond {
 	cols := make([]iface.Cond, len(desiredTimesOfDay))
-	// find hourly data which fits the desired times of day best
-	for _, candidate := range day.Slots {
-		cand := candidate.Time.UTC().Sub(candidate.Time.Truncate(24 * time.Hour))
+	for _, candidate := range slots {
+		cand := timeOfDay(candidate.Time)
 		for i, col := range cols {
-			cur := col.Time.Sub(col.Time.Truncate(24 * time.Hour))
+			cur := timeOfDay(col.Time)
 			if col.Time.IsZero() || math.Abs(float64(cand-desiredTimesOfDay[i])) < math.Abs(float64(cur-desiredTimesOfDay[i])) {
 				cols[i] = candidate
 			}
 		}
 	}
+	return cols
+}
+
+func (c *aatConfig) printDay(day iface.Day) (ret []string, err error) {
+	ret = make([]string, c.rows())
+	for i := range ret {
+		ret[i] = "│"
+	}
 
-	for _, s := range cols {
-		ret = c.formatCond(ret, s, false)
+	for _, s := range pickSlots(day.Slots) {
+		if ret, err = c.formatCond(ret, s, false); err != nil {
+			return nil, err
+		}
 		for i := range ret {
 			ret[i] = ret[i] + "│"
 		}
@@ -360,7 +720,7 @@ func (c *aatConfig) printDay(day iface.Day) (ret []string) {
 	dateFmt := "┤ " + day.Date.Format("Mon 02. Jan") + " ├"
 	if !c.compact {
 		ret = append([]string{
-			"                                                       ┌─────────────┐                                                       ",
+			aatPad(" "+c.formatAirQuality(day.AirQuality, false), 55) + "┌─────────────┐" + aatPad("  "+strings.TrimSpace(c.formatSummary(day.Summary)+"  "+c.formatMoon(day.Astronomy)), 55),
 			"┌──────────────────────────────┬───────────────────────" + dateFmt + "───────────────────────┬──────────────────────────────┐",
 			"│           Morning            │             Noon      └──────┬──────┘    Evening            │            Night             │",
 			"├──────────────────────────────┼──────────────────────────────┼──────────────────────────────┼──────────────────────────────┤"},
@@ -380,7 +740,7 @@ func (c *aatConfig) printDay(day iface.Day) (ret []string) {
 		bar := strings.Repeat("─", spaces)
 
 		ret = append([]string{
-			day.Date.Format("Mon 02. Jan"),
+			day.Date.Format("Mon 02. Jan") + "  " + c.formatSummary(day.Summary) + "  " + c.formatMoon(day.Astronomy) + "  " + c.formatAirQuality(day.AirQuality, false),
 			"┌" + merge("Morning", bar) + "┬" + merge("Noon", bar) + "┬" + merge("Evening", bar) + "┬" + merge("Night", bar) + "┐",
 		}, ret...)
 
@@ -389,7 +749,7 @@ func (c *aatConfig) printDay(day iface.Day) (ret []string) {
 		)
 	}
 
-	return ret
+	return ret, nil
 }
 
 func (c *aatConfig) Setup() {
@@ -397,33 +757,52 @@ func (c *aatConfig) Setup() {
 	flag.BoolVar(&c.monochrome, "aat-monochrome", false, "aat-frontend: Monochrome output")
 
 	flag.BoolVar(&c.compact, "aat-compact", false, "aat-frontend: Compact output")
+	flag.BoolVar(&c.aqiChina, "aat-aqi-china", false, "aat-frontend: Show the air quality index on the chinese instead of the US scale")
+	flag.BoolVar(&c.extended, "aat-extended", false, "aat-frontend: Show pressure, cloud cover, dew point, UV index and precipitation type")
 }
 
-func (c *aatConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+func (c *aatConfig) Render(w io.Writer, r iface.Data, unitSystem iface.UnitSystem) error {
 	c.unit = unitSystem
-
-	fmt.Printf("Weather for %s%s\n\n", r.Location, c.formatGeo(r.GeoLoc))
-	stdout := colorable.NewColorableStdout()
+	r = r.Local()
 	if c.monochrome {
-		stdout = colorable.NewNonColorable(os.Stdout)
+		w = colorable.NewNonColorable(w)
 	}
 
-	out := c.formatCond(make([]string, 5), r.Current, true)
-	for _, val := range out {
-		fmt.Fprintln(stdout, val)
+	fmt.Fprintf(w, "Weather for %s%s%s%s\n\n", r.Location, c.formatGeo(r.GeoLoc), sourceNote(r), precisionNote(r))
+	if stale := staleness(r, time.Now()); stale != "" {
+		fmt.Fprintf(w, "\033[1;33m%s\033[0m\n\n", stale)
 	}
-
-	if len(r.Forecast) == 0 {
-		return
+	if err := printLines(w, c.formatAlerts(r.Alerts)); err != nil {
+		return err
+	}
+	out, err := c.formatCond(make([]string, c.rows()), r.Current, true)
+	if err != nil {
+		return err
+	}
+	if aq := c.formatAirQuality(r.Current.AirQuality, true); aq != "" {
+		indent := "                "
+		if c.compact {
+			indent = "  "
+		}
+		out = append(out, indent+aq)
+	}
+	if nc := c.formatNowcast(r.Nowcast); nc != "" {
+		out = append(out, "", nc)
 	}
-	if r.Forecast == nil {
-		log.Fatal("No detailed weather forecast available.")
+	if err := printLines(w, out); err != nil {
+		return err
 	}
+
 	for _, d := range r.Forecast {
-		for _, val := range c.printDay(d) {
-			fmt.Fprintln(stdout, val)
+		out, err := c.printDay(d)
+		if err != nil {
+			return err
+		}
+		if err := printLines(w, out); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
 func init() {
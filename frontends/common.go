@@ -0,0 +1,49 @@
+package frontends
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// staleness describes how old the data is, e.g. "data from 3h ago", if it is
+// stale and returns an empty string otherwise.
+func staleness(r iface.Data, now time.Time) string {
+	if !r.Stale {
+		return ""
+	}
+	if r.Fetched.IsZero() {
+		return "outdated data"
+	}
+	age := now.Sub(r.Fetched)
+	switch {
+	case age < time.Hour:
+		return fmt.Sprintf("data from %dm ago", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("data from %dh ago", int(age.Hours()))
+	}
+	return fmt.Sprintf("data from %dd ago", int(age.Hours()/24))
+}
+
+// precisionNote returns a note on the precision the location was queried with,
+// e.g. " (location rounded to ~10 km)", or an empty string if the exact
+// location was queried.
+func precisionNote(r iface.Data) string {
+	switch {
+	case r.PrecisionKm <= 0:
+		return ""
+	case r.PrecisionKm < 1:
+		return fmt.Sprintf(" (location rounded to ~%.0f m)", r.PrecisionKm*1000)
+	}
+	return fmt.Sprintf(" (location rounded to ~%g km)", r.PrecisionKm)
+}
+
+// sourceNote returns the backend which delivered the data, e.g. " via smhi",
+// or an empty string if it is not known.
+func sourceNote(r iface.Data) string {
+	if r.Source == "" {
+		return ""
+	}
+	return " via " + r.Source
+}
@@ -0,0 +1,163 @@
+package frontends
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type compareConfig struct {
+	tempThreshold   float64
+	precipThreshold float64
+
+	aat aatConfig
+}
+
+// CompareOptions configures the compare frontend when it is used as a library.
+type CompareOptions struct {
+	// TempThresholdC highlights the temperatures of a time of day if the
+	// backends differ by more than that many degrees celsius. Zero highlights
+	// every difference.
+	TempThresholdC float64
+
+	// PrecipThresholdMM highlights the precipitation of a time of day if the
+	// backends differ by more than that many mm/h. Zero highlights every
+	// difference.
+	PrecipThresholdMM float64
+}
+
+// New returns a compare frontend configured by o.
+func (o CompareOptions) New() iface.Frontend {
+	return &compareConfig{tempThreshold: o.TempThresholdC, precipThreshold: o.PrecipThresholdMM}
+}
+
+// highlight returns the cell s in reverse video without its own colors.
+func highlight(s string, width int) string {
+	return aatPad("\033[7m"+strings.TrimRight(ansiEsc.ReplaceAllLiteralString(s, ""), " ")+"\033[0m", width)
+}
+
+// disagree reports for every column whether the values of the rows differ by
+// more than threshold. Missing values are ignored.
+func disagree(rows [][]*float32, threshold float64) []bool {
+	ret := make([]bool, len(desiredTimesOfDay))
+	for i := range ret {
+		var min, max *float32
+		for _, row := range rows {
+			v := row[i]
+			if v == nil {
+				continue
+			}
+			if min == nil || *v < *min {
+				min = v
+			}
+			if max == nil || *v > *max {
+				max = v
+			}
+		}
+		ret[i] = min != nil && float64(*max-*min) > threshold
+	}
+	return ret
+}
+
+// daySlots returns the slots of all days of d which are on the date of day.
+func daySlots(d iface.Data, day time.Time) (ret []iface.Cond) {
+	y, m, dd := day.Date()
+	for _, fd := range d.Forecast {
+		for _, slot := range fd.Slots {
+			if sy, sm, sd := slot.Time.Date(); sy == y && sm == m && sd == dd {
+				ret = append(ret, slot)
+			}
+		}
+	}
+	return ret
+}
+
+func (c *compareConfig) printDay(day iface.Day, members []iface.Data) []string {
+	bar := strings.Repeat("─", 30)
+	ret := []string{
+		day.Date.Format("Mon 02. Jan"),
+		"┌" + strings.Repeat("─", 16) + strings.Repeat("┬"+bar, 4) + "┐",
+		"│" + strings.Repeat(" ", 16) + "│" + aatPad(" Morning", 30) + "│" + aatPad(" Noon", 30) + "│" + aatPad(" Evening", 30) + "│" + aatPad(" Night", 30) + "│",
+		"├" + strings.Repeat("─", 16) + strings.Repeat("┼"+bar, 4) + "┤",
+	}
+
+	cols := make([][]iface.Cond, len(members))
+	temps := make([][]*float32, len(members))
+	precips := make([][]*float32, len(members))
+	for i, m := range members {
+		cols[i] = pickSlots(daySlots(m, day.Date))
+		for _, slot := range cols[i] {
+			if slot.Time.IsZero() {
+				slot = iface.Cond{}
+			}
+			var precip *float32
+			if slot.PrecipM != nil {
+				mm := *slot.PrecipM * 1000
+				precip = &mm
+			}
+			temps[i] = append(temps[i], slot.TempC)
+			precips[i] = append(precips[i], precip)
+		}
+	}
+	tempDiffers := disagree(temps, c.tempThreshold)
+	precipDiffers := disagree(precips, c.precipThreshold)
+
+	for i, m := range members {
+		line := "│" + aatPad(" "+m.Source, 16) + "│"
+		for j, slot := range cols[i] {
+			if slot.Time.IsZero() {
+				line += aatPad("", 30) + "│"
+				continue
+			}
+			temp, rain := c.aat.formatTemp(slot), c.aat.formatRain(slot)
+			if tempDiffers[j] && slot.TempC != nil {
+				temp = highlight(temp, 15)
+			}
+			if precipDiffers[j] && slot.PrecipM != nil {
+				rain = highlight(rain, 15)
+			}
+			line += temp + rain + "│"
+		}
+		ret = append(ret, line)
+	}
+	return append(ret, "└"+strings.Repeat("─", 16)+strings.Repeat("┴"+bar, 4)+"┘")
+}
+
+func (c *compareConfig) Setup() {
+	flag.Float64Var(&c.tempThreshold, "compare-temp-threshold", 3, "compare-frontend: Highlight temperatures if the backends differ by more than `DEGREES` celsius")
+	flag.Float64Var(&c.precipThreshold, "compare-precip-threshold", 1, "compare-frontend: Highlight precipitation if the backends differ by more than `MM` per hour")
+}
+
+// Render shows the forecasts of the backends r was merged from next to each
+// other, one row per backend.
+func (c *compareConfig) Render(w io.Writer, r iface.Data, unitSystem iface.UnitSystem) error {
+	if len(r.Members) < 2 {
+		return fmt.Errorf("compare frontend: needs the forecasts of at least two backends, got %d, e.g. use wego -compare smhi,openmeteo", len(r.Members))
+	}
+	c.aat.unit = unitSystem
+	r = r.Local()
+	members := make([]iface.Data, len(r.Members))
+	for i, m := range r.Members {
+		m.TimeZone = r.TimeZone
+		members[i] = m.Local()
+	}
+
+	fmt.Fprintf(w, "Comparison for %s%s\n\n", r.Location, precisionNote(r))
+	if stale := staleness(r, time.Now()); stale != "" {
+		fmt.Fprintf(w, "\033[1;33m%s\033[0m\n\n", stale)
+	}
+	for _, day := range r.Forecast {
+		if err := printLines(w, c.printDay(day, members)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	iface.AllFrontends["compare"] = &compareConfig{}
+}
@@ -2,11 +2,11 @@ package frontends
 
 import (
 	"fmt"
-	"log"
+	"io"
 	"math"
+	"strings"
 	"time"
 
-	colorable "github.com/mattn/go-colorable"
 	runewidth "github.com/mattn/go-runewidth"
 	"github.com/schachmat/wego/iface"
 )
@@ -15,6 +15,15 @@ type emojiConfig struct {
 	unit iface.UnitSystem
 }
 
+// EmojiOptions configures the emoji frontend when it is used as a library. The
+// frontend has no options yet.
+type EmojiOptions struct{}
+
+// New returns an emoji frontend.
+func (o EmojiOptions) New() iface.Frontend {
+	return &emojiConfig{}
+}
+
 func (c *emojiConfig) formatTemp(cond iface.Cond) string {
 	color := func(temp float32) string {
 		colmap := []struct {
@@ -45,6 +54,9 @@ func (c *emojiConfig) formatTemp(cond iface.Cond) string {
 	}
 
 	t := *cond.TempC
+	if min, max, ok := tempSpread(cond, c.unit); ok {
+		return aatPad(fmt.Sprintf("%s (%s–%s) %s", color(t), color(min), color(max), u), 12)
+	}
 	if cond.FeelsLikeC != nil {
 		fl := *cond.FeelsLikeC
 		return aatPad(fmt.Sprintf("%s (%s) %s", color(t), color(fl), u), 12)
@@ -52,32 +64,33 @@ func (c *emojiConfig) formatTemp(cond iface.Cond) string {
 	return aatPad(fmt.Sprintf("%s %s", color(t), u), 12)
 }
 
-func (c *emojiConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret []string) {
-	codes := map[iface.WeatherCode]string{
-		iface.CodeUnknown:             "✨",
-		iface.CodeCloudy:              "☁️",
-		iface.CodeFog:                 "🌫",
-		iface.CodeHeavyRain:           "🌧",
-		iface.CodeHeavyShowers:        "🌧",
-		iface.CodeHeavySnow:           "❄️",
-		iface.CodeHeavySnowShowers:    "❄️",
-		iface.CodeLightRain:           "🌦",
-		iface.CodeLightShowers:        "🌦",
-		iface.CodeLightSleet:          "🌧",
-		iface.CodeLightSleetShowers:   "🌧",
-		iface.CodeLightSnow:           "🌨",
-		iface.CodeLightSnowShowers:    "🌨",
-		iface.CodePartlyCloudy:        "⛅️",
-		iface.CodeSunny:               "☀️",
-		iface.CodeThunderyHeavyRain:   "🌩",
-		iface.CodeThunderyShowers:     "⛈",
-		iface.CodeThunderySnowShowers: "⛈",
-		iface.CodeVeryCloudy:          "☁️",
-	}
-
-	icon, ok := codes[cond.Code]
+// emojiCodes are the icons of the weather codes.
+var emojiCodes = map[iface.WeatherCode]string{
+	iface.CodeUnknown:             "✨",
+	iface.CodeCloudy:              "☁️",
+	iface.CodeFog:                 "🌫",
+	iface.CodeHeavyRain:           "🌧",
+	iface.CodeHeavyShowers:        "🌧",
+	iface.CodeHeavySnow:           "❄️",
+	iface.CodeHeavySnowShowers:    "❄️",
+	iface.CodeLightRain:           "🌦",
+	iface.CodeLightShowers:        "🌦",
+	iface.CodeLightSleet:          "🌧",
+	iface.CodeLightSleetShowers:   "🌧",
+	iface.CodeLightSnow:           "🌨",
+	iface.CodeLightSnowShowers:    "🌨",
+	iface.CodePartlyCloudy:        "⛅️",
+	iface.CodeSunny:               "☀️",
+	iface.CodeThunderyHeavyRain:   "🌩",
+	iface.CodeThunderyShowers:     "⛈",
+	iface.CodeThunderySnowShowers: "⛈",
+	iface.CodeVeryCloudy:          "☁️",
+}
+
+func (c *emojiConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret []string, err error) {
+	icon, ok := emojiCodes[cond.Code]
 	if !ok {
-		log.Fatalln("emoji-frontend: The following weather code has no icon:", cond.Code)
+		return nil, fmt.Errorf("emoji-frontend: The following weather code has no icon: %d", cond.Code)
 	}
 	if runewidth.StringWidth(icon) == 1 {
 		icon += " "
@@ -90,27 +103,115 @@ func (c *emojiConfig) formatCond(cur []string, cond iface.Cond, current bool) (r
 
 	ret = append(ret, fmt.Sprintf("%v %v %v", cur[0], "", desc))
 	ret = append(ret, fmt.Sprintf("%v%v %v", cur[1], icon, c.formatTemp(cond)))
-	return
+	return ret, nil
+}
+
+func (c *emojiConfig) printNowcast(nowcast []iface.Cond) string {
+	if len(nowcast) == 0 {
+		return ""
+	}
+	bars, max := nowcastBars(nowcast, 24)
+	if max == 0 {
+		return "🌂 no rain until " + nowcast[len(nowcast)-1].Time.Format(time.Kitchen)
+	}
+	v, u := c.unit.Distance(max)
+	return fmt.Sprintf("☔ %s ▕\033[38;5;33m%s\033[0m▏ %s  max %.1f %s/h", nowcast[0].Time.Format(time.Kitchen), bars, nowcast[len(nowcast)-1].Time.Format(time.Kitchen), v, u)
 }
 
-func (c *emojiConfig) printAstro(astro iface.Astro) {
-        // print sun astronomy data if present
+func (c *emojiConfig) printAlerts(alerts []iface.Alert) (ret []string) {
+	icons := map[iface.AlertSeverity]string{
+		iface.SeverityUnknown:  "⚠️ ",
+		iface.SeverityMinor:    "🔵",
+		iface.SeverityModerate: "🟡",
+		iface.SeveritySevere:   "🟠",
+		iface.SeverityExtreme:  "🔴",
+	}
+	for _, a := range alerts {
+		line := fmt.Sprintf("%s \033[1m%s\033[0m", icons[a.Severity], a.Title)
+		if period := alertPeriod(a); period != "" {
+			line += " (" + period + ")"
+		}
+		ret = append(ret, line)
+	}
+	if len(ret) > 0 {
+		ret = append(ret, "")
+	}
+	return ret
+}
+
+func (c *emojiConfig) printAstro(astro iface.Astro) (ret []string) {
+	// print sun astronomy data if present
 	if astro.Sunrise != astro.Sunset {
-	    // half the distance between sunrise and sunset
-	    noon_distance := time.Duration(int64(float32(astro.Sunset.UnixNano() - astro.Sunrise.UnixNano()) * 0.5))
-	    // time for solar noon
-	    noon := astro.Sunrise.Add(noon_distance)
+		// half the distance between sunrise and sunset
+		noon_distance := time.Duration(int64(float32(astro.Sunset.UnixNano()-astro.Sunrise.UnixNano()) * 0.5))
+		// time for solar noon
+		noon := astro.Sunrise.Add(noon_distance)
+		if !astro.SolarNoon.IsZero() {
+			noon = astro.SolarNoon
+		}
+
+		// the actual print statement
+		ret = append(ret, fmt.Sprintf("🌞 rise↗ %s noon↑ %s set↘ %s (%s)", astro.Sunrise.Format(time.Kitchen), noon.Format(time.Kitchen), astro.Sunset.Format(time.Kitchen), formatDayLength(astro.DayLength)))
+	}
+	// print civil twilight if present
+	if !astro.CivilDawn.IsZero() && !astro.CivilDusk.IsZero() {
+		ret = append(ret, fmt.Sprintf("🌆 dawn %s dusk %s", astro.CivilDawn.Format(time.Kitchen), astro.CivilDusk.Format(time.Kitchen)))
+	}
+	// print moon astronomy data if present
+	moon := "🌚"
+	if astro.MoonPhase != iface.MoonPhaseUnknown {
+		moon = fmt.Sprintf("%s %s %.0f%%", moonPhaseEmoji[astro.MoonPhase], moonPhaseNames[astro.MoonPhase], astro.MoonIllumination)
+	}
+	if !astro.Moonrise.IsZero() {
+		moon += " rise↗ " + astro.Moonrise.Format(time.Kitchen)
+	}
+	if !astro.Moonset.IsZero() {
+		moon += " set↘ " + astro.Moonset.Format(time.Kitchen)
+	}
+	if moon != "🌚" {
+		ret = append(ret, moon)
+	}
+	return ret
+}
 
-	    // the actual print statement
-	    fmt.Printf("🌞 rise↗ %s noon↑ %s set↘ %s\n", astro.Sunrise.Format(time.Kitchen), noon.Format(time.Kitchen), astro.Sunset.Format(time.Kitchen))
+func (c *emojiConfig) printSummary(s iface.Summary) (ret []string) {
+	var line string
+	if s.MinTempC != nil && s.MaxTempC != nil {
+		min, u := c.unit.Temp(*s.MinTempC)
+		max, _ := c.unit.Temp(*s.MaxTempC)
+		line = fmt.Sprintf("🌡️ %d – %d %s", int(min), int(max), u)
+		if s.MinFeelsLikeC != nil && s.MaxFeelsLikeC != nil {
+			min, _ = c.unit.Temp(*s.MinFeelsLikeC)
+			max, _ = c.unit.Temp(*s.MaxFeelsLikeC)
+			line += fmt.Sprintf(" (%d – %d)", int(min), int(max))
+		}
+		line += "  "
+	}
+	if s.PrecipSumM != nil {
+		v, u := c.unit.Distance(*s.PrecipSumM)
+		line += fmt.Sprintf("☔ %.1f %s", v, u)
+		if s.MaxChanceOfRainPercent != nil {
+			line += fmt.Sprintf(" %d%%", *s.MaxChanceOfRainPercent)
+		}
+		line += "  "
 	}
-        // print moon astronomy data if present
-	if astro.Moonrise != astro.Moonset {
-	    fmt.Printf("🌚 rise↗ %s set↘ %s\n", astro.Moonrise.Format(time.Kitchen), astro.Moonset)
+	if s.MaxWindGustKmph != nil {
+		v, u := c.unit.Speed(*s.MaxWindGustKmph)
+		line += fmt.Sprintf("💨 %d %s", int(v), u)
 	}
+	if line != "" {
+		ret = append(ret, strings.TrimSpace(line))
+	}
+	return ret
+}
+
+// formatDayLength formats d as hours and minutes, e.g. "10h23m".
+func formatDayLength(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
 }
 
-func (c *emojiConfig) printDay(day iface.Day) (ret []string) {
+func (c *emojiConfig) printDay(day iface.Day) (ret []string, err error) {
 	desiredTimesOfDay := []time.Duration{
 		8 * time.Hour,
 		12 * time.Hour,
@@ -122,15 +223,13 @@ func (c *emojiConfig) printDay(day iface.Day) (ret []string) {
 		ret[i] = "│"
 	}
 
-	c.printAstro(day.Astronomy)
-
 	// save our selected elements from day.Slots in this array
 	cols := make([]iface.Cond, len(desiredTimesOfDay))
 	// find hourly data which fits the desired times of day best
 	for _, candidate := range day.Slots {
-		cand := candidate.Time.UTC().Sub(candidate.Time.Truncate(24 * time.Hour))
+		cand := timeOfDay(candidate.Time)
 		for i, col := range cols {
-			cur := col.Time.Sub(col.Time.Truncate(24 * time.Hour))
+			cur := timeOfDay(col.Time)
 			if math.Abs(float64(cand-desiredTimesOfDay[i])) < math.Abs(float64(cur-desiredTimesOfDay[i])) {
 				cols[i] = candidate
 			}
@@ -138,50 +237,65 @@ func (c *emojiConfig) printDay(day iface.Day) (ret []string) {
 	}
 
 	for _, s := range cols {
-		ret = c.formatCond(ret, s, false)
+		if ret, err = c.formatCond(ret, s, false); err != nil {
+			return nil, err
+		}
 		for i := range ret {
 			ret[i] = ret[i] + "│"
 		}
 	}
 
 	dateFmt := "┤  " + day.Date.Format("Mon") + "  ├"
-	ret = append([]string{
+	ret = append(append(append(c.printSummary(day.Summary), c.printAstro(day.Astronomy)...), []string{
 		"                            ┌───────┐ ",
 		"┌───────────────┬───────────" + dateFmt + "───────────┬───────────────┐",
 		"│    Morning    │    Noon   └───┬───┘ Evening   │     Night     │",
-		"├───────────────┼───────────────┼───────────────┼───────────────┤"},
+		"├───────────────┼───────────────┼───────────────┼───────────────┤"}...),
 		ret...)
 	return append(ret,
 		"└───────────────┴───────────────┴───────────────┴───────────────┘",
-		" ")
+		" "), nil
 }
 
 func (c *emojiConfig) Setup() {
 }
 
-func (c *emojiConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+func (c *emojiConfig) Render(w io.Writer, r iface.Data, unitSystem iface.UnitSystem) error {
 	c.unit = unitSystem
+	r = r.Local()
 
-	fmt.Printf("Weather for %s\n\n", r.Location)
-	stdout := colorable.NewColorableStdout()
-
-	out := c.formatCond(make([]string, 5), r.Current, true)
-	for _, val := range out {
-		fmt.Fprintln(stdout, val)
+	fmt.Fprintf(w, "Weather for %s%s%s\n\n", r.Location, sourceNote(r), precisionNote(r))
+	if stale := staleness(r, time.Now()); stale != "" {
+		fmt.Fprintf(w, "⏳ %s\n\n", stale)
+	}
+	if err := printLines(w, c.printAlerts(r.Alerts)); err != nil {
+		return err
+	}
+	out, err := c.formatCond(make([]string, 5), r.Current, true)
+	if err != nil {
+		return err
+	}
+	if nc := c.printNowcast(r.Nowcast); nc != "" {
+		out = append(out, "", nc)
+	}
+	if err := printLines(w, out); err != nil {
+		return err
 	}
 
 	if len(r.Forecast) == 0 {
-		return
+		return nil
 	}
-	if r.Forecast == nil {
-		log.Fatal("No detailed weather forecast available.")
-	}
-	fmt.Printf("\n")
+	fmt.Fprintf(w, "\n")
 	for _, d := range r.Forecast {
-		for _, val := range c.printDay(d) {
-			fmt.Fprintln(stdout, val)
+		out, err := c.printDay(d)
+		if err != nil {
+			return err
+		}
+		if err := printLines(w, out); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
 func init() {
@@ -3,8 +3,7 @@ package frontends
 import (
 	"encoding/json"
 	"flag"
-	"log"
-	"os"
+	"io"
 
 	"github.com/schachmat/wego/iface"
 )
@@ -13,11 +12,22 @@ type jsnConfig struct {
 	noIndent bool
 }
 
+// JSONOptions configures the json frontend when it is used as a library.
+type JSONOptions struct {
+	// NoIndent writes the json data without indentation.
+	NoIndent bool
+}
+
+// New returns a json frontend configured by o.
+func (o JSONOptions) New() iface.Frontend {
+	return &jsnConfig{noIndent: o.NoIndent}
+}
+
 func (c *jsnConfig) Setup() {
 	flag.BoolVar(&c.noIndent, "jsn-no-indent", false, "json frontend: do not indent the output")
 }
 
-func (c *jsnConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+func (c *jsnConfig) Render(w io.Writer, r iface.Data, unitSystem iface.UnitSystem) error {
 	var b []byte
 	var err error
 	if c.noIndent {
@@ -26,9 +36,10 @@ func (c *jsnConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
 		b, err = json.MarshalIndent(r, "", "\t")
 	}
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	os.Stdout.Write(b)
+	_, err = w.Write(b)
+	return err
 }
 
 func init() {
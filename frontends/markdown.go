@@ -3,20 +3,35 @@ package frontends
 import (
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"math"
-	"os"
 	"strings"
 	"time"
 
-	"github.com/mattn/go-colorable"
 	"github.com/mattn/go-runewidth"
 	"github.com/schachmat/wego/iface"
 )
 
 type mdConfig struct {
-	coords     bool
-	unit       iface.UnitSystem
+	coords   bool
+	extended bool
+	unit     iface.UnitSystem
+}
+
+// MarkdownOptions configures the markdown frontend when it is used as a
+// library.
+type MarkdownOptions struct {
+	// Coords shows the geo coordinates next to the location.
+	Coords bool
+
+	// Extended shows pressure, cloud cover, dew point, UV index and the kind of
+	// precipitation in additional rows.
+	Extended bool
+}
+
+// New returns a markdown frontend configured by o.
+func (o MarkdownOptions) New() iface.Frontend {
+	return &mdConfig{coords: o.Coords, extended: o.Extended}
 }
 
 func mdPad(s string, mustLen int) (ret string) {
@@ -39,7 +54,7 @@ func mdPad(s string, mustLen int) (ret string) {
 
 func (c *mdConfig) formatTemp(cond iface.Cond) string {
 
-	cvtUnits := func (temp float32) string {
+	cvtUnits := func(temp float32) string {
 		t, _ := c.unit.Temp(temp)
 		return fmt.Sprintf("%d", int(t))
 	}
@@ -50,6 +65,9 @@ func (c *mdConfig) formatTemp(cond iface.Cond) string {
 	}
 
 	t := *cond.TempC
+	if min, max, ok := tempSpread(cond, c.unit); ok {
+		return mdPad(fmt.Sprintf("%s (%s–%s) %s", cvtUnits(t), cvtUnits(min), cvtUnits(max), u), 15)
+	}
 	if cond.FeelsLikeC != nil {
 		fl := *cond.FeelsLikeC
 		return mdPad(fmt.Sprintf("%s (%s) %s", cvtUnits(t), cvtUnits(fl), u), 15)
@@ -108,7 +126,38 @@ func (c *mdConfig) formatRain(cond iface.Cond) string {
 	return mdPad("", 15)
 }
 
-func (c *mdConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret []string) {
+func (c *mdConfig) formatAtmosphere(cond iface.Cond) string {
+	var parts []string
+	if cond.PressureHPa != nil {
+		parts = append(parts, fmt.Sprintf("%d hPa", int(*cond.PressureHPa)))
+	}
+	if cond.CloudCoverPercent != nil {
+		parts = append(parts, fmt.Sprintf("☁ %d%%", *cond.CloudCoverPercent))
+	}
+	return mdPad(strings.Join(parts, " "), 15)
+}
+
+func (c *mdConfig) formatDewPointUV(cond iface.Cond) string {
+	var parts []string
+	if cond.DewPointC != nil {
+		t, u := c.unit.Temp(*cond.DewPointC)
+		parts = append(parts, fmt.Sprintf("dew %d%s", int(t), u))
+	}
+	if cond.UVIndex != nil {
+		parts = append(parts, fmt.Sprintf("UV %d", int(*cond.UVIndex)))
+	}
+	return mdPad(strings.Join(parts, " "), 15)
+}
+
+func (c *mdConfig) formatPrecipType(cond iface.Cond) string {
+	if cond.SnowfallM != nil && *cond.SnowfallM > 0 {
+		v, u := c.unit.Distance(*cond.SnowfallM)
+		return mdPad(fmt.Sprintf("❄ %.1f %s/h", v, u), 15)
+	}
+	return mdPad(precipNames[cond.PrecipType], 15)
+}
+
+func (c *mdConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret []string, err error) {
 	codes := map[iface.WeatherCode]string{
 		iface.CodeUnknown:             "✨",
 		iface.CodeCloudy:              "☁️",
@@ -133,7 +182,7 @@ func (c *mdConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret
 
 	icon, ok := codes[cond.Code]
 	if !ok {
-		log.Fatalln("markdown-frontend: The following weather code has no icon:", cond.Code)
+		return nil, fmt.Errorf("markdown-frontend: The following weather code has no icon: %d", cond.Code)
 	}
 
 	desc := cond.Desc
@@ -143,7 +192,34 @@ func (c *mdConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret
 
 	ret = append(ret, fmt.Sprintf("%v %v %v", cur[0], "", desc))
 	ret = append(ret, fmt.Sprintf("%v %v %v", cur[1], icon, c.formatTemp(cond)))
-	return
+	if c.extended {
+		ret = append(ret, fmt.Sprintf("%v %v %v", cur[2], "", c.formatAtmosphere(cond)))
+		ret = append(ret, fmt.Sprintf("%v %v %v", cur[3], "", c.formatDewPointUV(cond)))
+		ret = append(ret, fmt.Sprintf("%v %v %v", cur[4], "", c.formatPrecipType(cond)))
+	}
+	return ret, nil
+}
+
+func (c *mdConfig) formatAlerts(alerts []iface.Alert) (ret []string) {
+	for _, a := range alerts {
+		head := fmt.Sprintf("> **⚠ %s: %s**", severityNames[a.Severity], a.Title)
+		details := []string{}
+		if period := alertPeriod(a); period != "" {
+			details = append(details, period)
+		}
+		if a.Source != "" {
+			details = append(details, a.Source)
+		}
+		if len(details) > 0 {
+			head += " (" + strings.Join(details, ", ") + ")"
+		}
+		ret = append(ret, head)
+		if a.Description != "" {
+			ret = append(ret, ">", "> "+strings.Join(strings.Fields(a.Description), " "))
+		}
+		ret = append(ret, "")
+	}
+	return ret
 }
 
 func (c *mdConfig) formatGeo(coords *iface.LatLon) (ret string) {
@@ -164,7 +240,7 @@ func (c *mdConfig) formatGeo(coords *iface.LatLon) (ret string) {
 	return
 }
 
-func (c *mdConfig) printDay(day iface.Day) (ret []string) {
+func (c *mdConfig) printDay(day iface.Day) (ret []string, err error) {
 	desiredTimesOfDay := []time.Duration{
 		8 * time.Hour,
 		12 * time.Hour,
@@ -180,9 +256,9 @@ func (c *mdConfig) printDay(day iface.Day) (ret []string) {
 	cols := make([]iface.Cond, len(desiredTimesOfDay))
 	// find hourly data which fits the desired times of day best
 	for _, candidate := range day.Slots {
-		cand := candidate.Time.UTC().Sub(candidate.Time.Truncate(24 * time.Hour))
+		cand := timeOfDay(candidate.Time)
 		for i, col := range cols {
-			cur := col.Time.Sub(col.Time.Truncate(24 * time.Hour))
+			cur := timeOfDay(col.Time)
 			if col.Time.IsZero() || math.Abs(float64(cand-desiredTimesOfDay[i])) < math.Abs(float64(cur-desiredTimesOfDay[i])) {
 				cols[i] = candidate
 			}
@@ -190,44 +266,94 @@ func (c *mdConfig) printDay(day iface.Day) (ret []string) {
 	}
 
 	for _, s := range cols {
-		ret = c.formatCond(ret, s, false)
+		if ret, err = c.formatCond(ret, s, false); err != nil {
+			return nil, err
+		}
 		for i := range ret {
 			ret[i] = ret[i] + "|"
 		}
 	}
 	dateFmt := day.Date.Format("Mon Jan 02")
-	ret = append([]string{
-		"\n### Forecast for "+dateFmt+ "\n",
+	if astro := day.Astronomy; astro.MoonPhase != iface.MoonPhaseUnknown {
+		dateFmt += fmt.Sprintf(" %s %s %.0f%%", moonPhaseEmoji[astro.MoonPhase], moonPhaseNames[astro.MoonPhase], astro.MoonIllumination)
+	}
+	head := []string{"\n### Forecast for " + dateFmt + "\n"}
+	if summary := c.formatSummary(day.Summary); summary != "" {
+		head = append(head, summary)
+	}
+	ret = append(append(head,
 		"| Morning                   | Noon                      | Evening                   | Night                     |",
-		"| ------------------------- | ------------------------- | ------------------------- | ------------------------- |"},
+		"| ------------------------- | ------------------------- | ------------------------- | ------------------------- |"),
 		ret...)
-	return ret
+	return ret, nil
+}
+
+// formatSummary returns the day summary s as a paragraph or an empty string if
+// nothing is known about the day.
+func (c *mdConfig) formatSummary(s iface.Summary) string {
+	var parts []string
+	_, tu := c.unit.Temp(0.0)
+	if s.MinTempC != nil && s.MaxTempC != nil {
+		min, _ := c.unit.Temp(*s.MinTempC)
+		max, _ := c.unit.Temp(*s.MaxTempC)
+		parts = append(parts, fmt.Sprintf("**%d – %d %s**", int(min), int(max), tu))
+	}
+	if s.MinFeelsLikeC != nil && s.MaxFeelsLikeC != nil {
+		min, _ := c.unit.Temp(*s.MinFeelsLikeC)
+		max, _ := c.unit.Temp(*s.MaxFeelsLikeC)
+		parts = append(parts, fmt.Sprintf("feels like %d – %d %s", int(min), int(max), tu))
+	}
+	if s.PrecipSumM != nil {
+		v, u := c.unit.Distance(*s.PrecipSumM)
+		rain := fmt.Sprintf("%.1f %s precipitation", v, u)
+		if s.MaxChanceOfRainPercent != nil {
+			rain += fmt.Sprintf(" (%d%%)", *s.MaxChanceOfRainPercent)
+		}
+		parts = append(parts, rain)
+	}
+	if s.MaxWindGustKmph != nil {
+		v, u := c.unit.Speed(*s.MaxWindGustKmph)
+		parts = append(parts, fmt.Sprintf("gusts up to %d %s", int(v), u))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, ", ") + "\n"
 }
 
 func (c *mdConfig) Setup() {
 	flag.BoolVar(&c.coords, "md-coords", false, "md-frontend: Show geo coordinates")
+	flag.BoolVar(&c.extended, "md-extended", false, "md-frontend: Show pressure, cloud cover, dew point, UV index and precipitation type")
 }
 
-func (c *mdConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+func (c *mdConfig) Render(w io.Writer, r iface.Data, unitSystem iface.UnitSystem) error {
 	c.unit = unitSystem
-	fmt.Printf("## Weather for %s%s\n\n", r.Location, c.formatGeo(r.GeoLoc))
-	stdout := colorable.NewNonColorable(os.Stdout)
-	out := c.formatCond(make([]string, 5), r.Current, true)
-	for _, val := range out {
-		fmt.Fprintln(stdout, val)
+	r = r.Local()
+	fmt.Fprintf(w, "## Weather for %s%s%s%s\n\n", r.Location, c.formatGeo(r.GeoLoc), sourceNote(r), precisionNote(r))
+	if stale := staleness(r, time.Now()); stale != "" {
+		fmt.Fprintf(w, "*%s*\n\n", stale)
 	}
-
-	if len(r.Forecast) == 0 {
-		return
+	if err := printLines(w, c.formatAlerts(r.Alerts)); err != nil {
+		return err
+	}
+	out, err := c.formatCond(make([]string, 5), r.Current, true)
+	if err != nil {
+		return err
 	}
-	if r.Forecast == nil {
-		log.Fatal("No detailed weather forecast available.")
+	if err := printLines(w, out); err != nil {
+		return err
 	}
+
 	for _, d := range r.Forecast {
-		for _, val := range c.printDay(d) {
-			fmt.Fprintln(stdout, val)
+		out, err := c.printDay(d)
+		if err != nil {
+			return err
+		}
+		if err := printLines(w, out); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
 func init() {
@@ -0,0 +1,69 @@
+package frontends
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/schachmat/wego/iface"
+)
+
+// today returns the forecast of the current day at the location of r, or the
+// first day of the forecast if it does not contain the current day.
+func today(r iface.Data, now time.Time) (iface.Day, bool) {
+	if len(r.Forecast) == 0 {
+		return iface.Day{}, false
+	}
+	y, m, d := r.TimeZone.In(now).Date()
+	for _, day := range r.Forecast {
+		if dy, dm, dd := day.Date.Date(); dy == y && dm == m && dd == d {
+			return day, true
+		}
+	}
+	return r.Forecast[0], true
+}
+
+// Overview writes one line per location in data with the current weather, the
+// lowest and highest temperature of today and the chance of rain. It is meant
+// to show many locations at once.
+func Overview(w io.Writer, data []iface.Data, unitSystem iface.UnitSystem) error {
+	c := &aatConfig{unit: unitSystem}
+	_, u := unitSystem.Temp(0)
+	now := time.Now()
+
+	width := 0
+	for _, r := range data {
+		if n := runewidth.StringWidth(r.Location); n > width {
+			width = n
+		}
+	}
+
+	for _, r := range data {
+		icon := emojiCodes[r.Current.Code]
+		if runewidth.StringWidth(icon) == 1 {
+			icon += " "
+		}
+		temp := "?"
+		if r.Current.TempC != nil {
+			temp = c.colorTemp(*r.Current.TempC)
+		}
+		minMax, rain := "", ""
+		if day, ok := today(r, now); ok {
+			if s := day.Summary; s.MinTempC != nil && s.MaxTempC != nil {
+				minMax = fmt.Sprintf("%s – %s %s", c.colorTemp(*s.MinTempC), c.colorTemp(*s.MaxTempC), u)
+			}
+			if p := day.Summary.MaxChanceOfRainPercent; p != nil {
+				rain = fmt.Sprintf("☔ %d%%", *p)
+			}
+		}
+		line := runewidth.FillRight(r.Location, width) + "  " + icon + " " + aatPad(temp+" "+u, 8) + aatPad(minMax, 14) + aatPad(rain, 7)
+		if stale := staleness(r, now); stale != "" {
+			line += "  \033[1;33m" + stale + "\033[0m"
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
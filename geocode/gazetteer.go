@@ -0,0 +1,170 @@
+package geocode
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/schachmat/wego/iface"
+)
+
+//go:embed cities.tsv
+var builtinCities string
+
+var (
+	builtinOnce      sync.Once
+	builtinGazetteer *Gazetteer
+)
+
+// Gazetteer is an offline Geocoder and ReverseGeocoder working on a list of
+// places.
+type Gazetteer struct {
+	places []Place
+	// names holds the lower case names, ascii names and alternate names of
+	// the place with the same index.
+	names [][]string
+}
+
+// Builtin returns the Gazetteer of the cities embedded in wego. It contains
+// the capitals and major cities of most countries.
+func Builtin() *Gazetteer {
+	builtinOnce.Do(func() {
+		var err error
+		builtinGazetteer, err = ParseGazetteer(strings.NewReader(builtinCities))
+		if err != nil {
+			panic("geocode: embedded cities.tsv is broken: " + err.Error())
+		}
+	})
+	return builtinGazetteer
+}
+
+// LoadGazetteer reads a Gazetteer from the file at path. See ParseGazetteer for
+// the format.
+func LoadGazetteer(path string) (*Gazetteer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	g, err := ParseGazetteer(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return g, nil
+}
+
+// ParseGazetteer reads places in the tab separated format of the GeoNames
+// cities files, e.g. cities15000.txt from https://download.geonames.org/export/dump/.
+// Empty lines and lines starting with '#' are ignored.
+func ParseGazetteer(r io.Reader) (*Gazetteer, error) {
+	g := &Gazetteer{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		cols := strings.Split(text, "\t")
+		if len(cols) < 18 {
+			return nil, fmt.Errorf("line %d: expected at least 18 columns, got %d", line, len(cols))
+		}
+		lat, err := strconv.ParseFloat(cols[4], 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid latitude: %v", line, err)
+		}
+		lon, err := strconv.ParseFloat(cols[5], 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid longitude: %v", line, err)
+		}
+		pop, _ := strconv.Atoi(cols[14])
+
+		names := []string{strings.ToLower(cols[1]), strings.ToLower(cols[2])}
+		for _, alt := range strings.Split(cols[3], ",") {
+			if alt != "" {
+				names = append(names, strings.ToLower(alt))
+			}
+		}
+		g.places = append(g.places, Place{
+			Name:       cols[1],
+			Country:    cols[8],
+			Admin1:     cols[10],
+			LatLon:     iface.LatLon{Latitude: float32(lat), Longitude: float32(lon)},
+			Population: pop,
+			TimeZone:   cols[17],
+		})
+		g.names = append(g.names, names)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Geocode returns the most populated place whose name, ascii name or one of its
+// alternate names equals the name in query ignoring case and which matches all
+// qualifiers.
+func (g *Gazetteer) Geocode(ctx context.Context, query string) (Place, error) {
+	name, qualifiers := splitQuery(query)
+	name = strings.ToLower(name)
+
+	best := -1
+	for i, p := range g.places {
+		if !p.matches(qualifiers) || (best >= 0 && p.Population <= g.places[best].Population) {
+			continue
+		}
+		for _, n := range g.names[i] {
+			if n == name {
+				best = i
+				break
+			}
+		}
+	}
+	if best < 0 {
+		return Place{}, fmt.Errorf("%w: no place called `%s` in the gazetteer.\nTry coordinates like `59.329,18.068` or another --geocoder", iface.ErrUnknownLocation, query)
+	}
+	return g.places[best], nil
+}
+
+// ReverseGeocode returns the place of the gazetteer closest to loc. The
+// builtin gazetteer only knows big cities, so the closest one might be far
+// away.
+func (g *Gazetteer) ReverseGeocode(ctx context.Context, loc iface.LatLon) (Place, float64, error) {
+	best, bestKm := -1, math.Inf(1)
+	for i, p := range g.places {
+		if km := Distance(loc, p.LatLon); km < bestKm {
+			best, bestKm = i, km
+		}
+	}
+	if best < 0 {
+		return Place{}, 0, fmt.Errorf("%w: the gazetteer is empty", iface.ErrUnknownLocation)
+	}
+	return g.places[best], bestKm, nil
+}
+
+// maxTimeZoneDistance is the maximum distance in kilometers of the closest
+// place whose time zone is used for a location.
+const maxTimeZoneDistance = 300
+
+// TimeZone returns the time zone of the place closest to loc. If the closest
+// place is too far away or has no time zone, the nautical time zone of the
+// longitude is returned.
+func (g *Gazetteer) TimeZone(loc iface.LatLon) iface.TimeZone {
+	if p, km, err := g.ReverseGeocode(context.Background(), loc); err == nil && km <= maxTimeZoneDistance && p.TimeZone != "" {
+		if tz, err := iface.LoadTimeZone(p.TimeZone); err == nil {
+			return tz
+		}
+	}
+	return iface.LongitudeTimeZone(loc.Longitude)
+}
+
+// TimeZoneAt returns the time zone at loc according to the builtin gazetteer.
+func TimeZoneAt(loc iface.LatLon) iface.TimeZone {
+	return Builtin().TimeZone(loc)
+}
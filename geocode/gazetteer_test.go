@@ -0,0 +1,127 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// testCities is a small gazetteer in the GeoNames format. The columns are
+// geonameid, name, asciiname, alternatenames, latitude, longitude, feature
+// class, feature code, country code, cc2, admin1 code, admin2 code, admin3
+// code, admin4 code, population, elevation, dem and timezone.
+var testCities = strings.Join([]string{
+	"# comment",
+	"",
+	"1\tSpringfield\tSpringfield\t\t37.21533\t-93.29824\tP\tPPL\tUS\t\tMO\t\t\t\t169176\t\t\tAmerica/Chicago\t",
+	"2\tSpringfield\tSpringfield\t\t42.10148\t-72.58981\tP\tPPL\tUS\t\tMA\t\t\t\t155929\t\t\tAmerica/New_York\t",
+	"3\tSpringfield\tSpringfield\t\t39.80172\t-89.64371\tP\tPPLA\tUS\t\tIL\t\t\t\t114394\t\t\tAmerica/Chicago\t",
+	"4\tMünchen\tMuenchen\tMunich,Monaco di Baviera\t48.13743\t11.57549\tP\tPPLA\tDE\t\t02\t\t\t\t1260391\t\t\tEurope/Berlin\t",
+	"5\tParis\tParis\t\t48.85341\t2.3488\tP\tPPLC\tFR\t\t11\t\t\t\t2138551\t\t\tEurope/Paris\t",
+	"6\tParis\tParis\t\t33.66094\t-95.55551\tP\tPPLA2\tUS\t\tTX\t\t\t\t24782\t\t\tAmerica/Chicago\t",
+	"7\tNowhere\tNowhere\t\t10\t-30\tP\tPPL\tXX\t\t\t\t\t\t10\t\t\t\t",
+}, "\n")
+
+func testGazetteer(t *testing.T) *Gazetteer {
+	t.Helper()
+	g, err := ParseGazetteer(strings.NewReader(testCities))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestGazetteerGeocode(t *testing.T) {
+	g := testGazetteer(t)
+	tests := []struct {
+		query   string
+		country string
+		admin1  string
+	}{
+		{"Springfield", "US", "MO"},
+		{"springfield, IL", "US", "IL"},
+		{"Springfield, MA, US", "US", "MA"},
+		{"Paris", "FR", "11"},
+		{"Paris, TX", "US", "TX"},
+		{"Paris,us", "US", "TX"},
+		{"München", "DE", "02"},
+		{"muenchen", "DE", "02"},
+		{"Munich", "DE", "02"},
+	}
+
+	for _, test := range tests {
+		p, err := g.Geocode(context.Background(), test.query)
+		if err != nil {
+			t.Errorf("Geocode(%q): %v", test.query, err)
+			continue
+		}
+		if p.Country != test.country || p.Admin1 != test.admin1 {
+			t.Errorf("Geocode(%q) = %s, %s, %s, want one in %s, %s", test.query, p.Name, p.Admin1, p.Country, test.admin1, test.country)
+		}
+	}
+
+	for _, query := range []string{"Atlantis", "Springfield, TX", "Paris, DE", "Spring"} {
+		if _, err := g.Geocode(context.Background(), query); !errors.Is(err, iface.ErrUnknownLocation) {
+			t.Errorf("Geocode(%q) = %v, want ErrUnknownLocation", query, err)
+		}
+	}
+}
+
+func TestGazetteerReverseGeocode(t *testing.T) {
+	g := testGazetteer(t)
+	p, km, err := g.ReverseGeocode(context.Background(), iface.LatLon{Latitude: 48.2, Longitude: 11.6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "München" || km < 5 || km > 10 {
+		t.Errorf("got %s %.1f km away, want München about 7 km away", p.Name, km)
+	}
+
+	empty, _ := ParseGazetteer(strings.NewReader(""))
+	if _, _, err := empty.ReverseGeocode(context.Background(), iface.LatLon{}); !errors.Is(err, iface.ErrUnknownLocation) {
+		t.Errorf("empty gazetteer: got %v, want ErrUnknownLocation", err)
+	}
+}
+
+func TestGazetteerTimeZone(t *testing.T) {
+	g := testGazetteer(t)
+	tests := []struct {
+		name string
+		loc  iface.LatLon
+		want string
+	}{
+		{"close to a city", iface.LatLon{Latitude: 48.9, Longitude: 2.4}, "Europe/Paris"},
+		{"city without time zone", iface.LatLon{Latitude: 10, Longitude: -30}, "Etc/GMT+2"},
+		{"far from every city", iface.LatLon{Latitude: -40, Longitude: 100}, "Etc/GMT-7"},
+	}
+
+	for _, test := range tests {
+		if got := g.TimeZone(test.loc); got.String() != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseGazetteerErrors(t *testing.T) {
+	for _, in := range []string{
+		"1\tShort\tShort",
+		"1\tBad\tBad\t\tnorth\t0\tP\tPPL\tXX\t\t\t\t\t\t0\t\t\t",
+	} {
+		if _, err := ParseGazetteer(strings.NewReader(in)); err == nil {
+			t.Errorf("ParseGazetteer(%q) succeeded", in)
+		}
+	}
+}
+
+func TestBuiltin(t *testing.T) {
+	p, err := Builtin().Geocode(context.Background(), "Stockholm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Country != "SE" || p.TimeZone != "Europe/Stockholm" {
+		t.Errorf("got %+v, want Stockholm in Sweden", p)
+	}
+}
@@ -0,0 +1,86 @@
+// Package geocode resolves place names to coordinates. The Gazetteer works
+// offline on a GeoNames style cities file, OpenMeteo asks the geocoding API of
+// open-meteo.com.
+package geocode
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// Place is a populated place returned by a Geocoder.
+type Place struct {
+	// Name is the name of the place, e.g. "Springfield".
+	Name string
+
+	// Country is the upper case ISO 3166 country code, e.g. "US".
+	Country string
+
+	// Admin1 is the code or name of the first level administrative division,
+	// e.g. the state "IL" for places in the US.
+	Admin1 string
+
+	LatLon     iface.LatLon
+	Population int
+
+	// TimeZone is the IANA name of the time zone, e.g. "America/Chicago". It
+	// is empty if unknown.
+	TimeZone string
+}
+
+// Geocoder resolves a free text query to a Place.
+type Geocoder interface {
+	// Geocode returns the best match for query, which is a place name
+	// optionally followed by comma separated admin1 and country qualifiers like
+	// "Springfield, IL, US" or "Paris, FR". If nothing matches, the error wraps
+	// iface.ErrUnknownLocation.
+	Geocode(ctx context.Context, query string) (Place, error)
+}
+
+// ReverseGeocoder finds the place closest to some coordinates.
+type ReverseGeocoder interface {
+	// ReverseGeocode returns the place closest to loc and its distance from loc
+	// in kilometers.
+	ReverseGeocode(ctx context.Context, loc iface.LatLon) (place Place, km float64, err error)
+}
+
+// earthRadius is the mean radius of the earth in kilometers.
+const earthRadius = 6371.0
+
+// Distance returns the great circle distance between a and b in kilometers.
+func Distance(a, b iface.LatLon) float64 {
+	const rad = math.Pi / 180
+	lat1, lat2 := float64(a.Latitude)*rad, float64(b.Latitude)*rad
+	dlat := lat2 - lat1
+	dlon := float64(b.Longitude-a.Longitude) * rad
+	h := math.Sin(dlat/2)*math.Sin(dlat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dlon/2)*math.Sin(dlon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(math.Min(h, 1)))
+}
+
+// splitQuery splits query into the place name and its lower case qualifiers.
+func splitQuery(query string) (name string, qualifiers []string) {
+	parts := strings.Split(query, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	for _, q := range parts[1:] {
+		if q != "" {
+			qualifiers = append(qualifiers, strings.ToLower(q))
+		}
+	}
+	return parts[0], qualifiers
+}
+
+// matches reports whether every qualifier equals the country or the admin1 of
+// p, so "Portland, ME" finds the one in Maine and "Paris, FR" the one in France.
+func (p Place) matches(qualifiers []string) bool {
+	for _, q := range qualifiers {
+		if q != strings.ToLower(p.Country) && q != strings.ToLower(p.Admin1) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/schachmat/wego/httpclient"
+	"github.com/schachmat/wego/iface"
+)
+
+const openmeteoGeocodingURI = "https://geocoding-api.open-meteo.com/v1/search?count=20&format=json&name="
+
+// OpenMeteo is a Geocoder using the free geocoding API of open-meteo.com. The
+// qualifiers of a query can be country codes, country names, admin1 names
+// like "Illinois" or admin1 codes from the GeoNames database.
+type OpenMeteo struct{}
+
+type openmeteoGeocodingResponse struct {
+	Results []struct {
+		Name        string  `json:"name"`
+		Latitude    float32 `json:"latitude"`
+		Longitude   float32 `json:"longitude"`
+		CountryCode string  `json:"country_code"`
+		Country     string  `json:"country"`
+		Admin1      string  `json:"admin1"`
+		Population  int     `json:"population"`
+		Timezone    string  `json:"timezone"`
+	} `json:"results"`
+	Reason string `json:"reason"`
+}
+
+func (o OpenMeteo) Geocode(ctx context.Context, query string) (Place, error) {
+	name, qualifiers := splitQuery(query)
+	requri := openmeteoGeocodingURI + url.QueryEscape(name)
+
+	body, err := httpclient.Get(ctx, requri)
+	var resp openmeteoGeocodingResponse
+	if err != nil {
+		if body != nil && json.Unmarshal(body, &resp) == nil && resp.Reason != "" {
+			return Place{}, fmt.Errorf("%w: %s", err, resp.Reason)
+		}
+		return Place{}, err
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return Place{}, fmt.Errorf("%w: unable to unmarshal geocoding response (%s): %v", iface.ErrParse, requri, err)
+	}
+
+	for _, r := range resp.Results {
+		p := Place{
+			Name:       r.Name,
+			Country:    r.CountryCode,
+			Admin1:     r.Admin1,
+			LatLon:     iface.LatLon{Latitude: r.Latitude, Longitude: r.Longitude},
+			Population: r.Population,
+			TimeZone:   r.Timezone,
+		}
+		// also accept the full country name as qualifier
+		country := Place{Country: strings.ToLower(r.Country), Admin1: p.Admin1}
+		if p.matches(qualifiers) || country.matches(qualifiers) {
+			return p, nil
+		}
+	}
+	return Place{}, fmt.Errorf("%w: open-meteo.com does not know a place called `%s`", iface.ErrUnknownLocation, query)
+}
@@ -4,12 +4,12 @@ go 1.20
 
 require (
 	github.com/mattn/go-colorable v0.1.14
+	github.com/mattn/go-isatty v0.0.20
 	github.com/mattn/go-runewidth v0.0.16
 	github.com/schachmat/ingo v0.0.0-20170403011506-a4bdc0729a3f
 )
 
 require (
-	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/rivo/uniseg v0.4.4 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 )
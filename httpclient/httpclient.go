@@ -0,0 +1,274 @@
+// Package httpclient provides the HTTP client used by all wego backends and
+// geocoders. It sets timeouts and a User-Agent, requests gzip compressed
+// responses and retries requests which failed with a 429 or 5xx status.
+package httpclient
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// UserAgent is sent with every request.
+const UserAgent = "wego (+https://github.com/schachmat/wego)"
+
+const (
+	// DefaultTimeout is the timeout of a single request if none is configured.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultRetries is the number of retries of the Default client and the
+	// command line.
+	DefaultRetries = 2
+
+	// minBackoff is the wait before the first retry. It doubles with every
+	// further retry.
+	minBackoff = 500 * time.Millisecond
+
+	// maxBackoff is the longest wait before a retry. A server asking for a
+	// longer wait with a Retry-After header is not retried.
+	maxBackoff = 30 * time.Second
+)
+
+// after waits before a retry. Tests replace it to not wait.
+var after = time.After
+
+// Policy decides how requests to plain http URLs are treated.
+type Policy int
+
+const (
+	// Upgrade requests plain http URLs with https instead.
+	Upgrade Policy = iota
+	// Refuse fails requests to plain http URLs.
+	Refuse
+	// Allow requests plain http URLs unencrypted.
+	Allow
+)
+
+// ParsePolicy parses the policy names "upgrade", "refuse" and "off".
+func ParsePolicy(s string) (Policy, error) {
+	switch s {
+	case "upgrade":
+		return Upgrade, nil
+	case "refuse":
+		return Refuse, nil
+	case "off":
+		return Allow, nil
+	}
+	return Upgrade, fmt.Errorf("unknown https policy %q, choices are: upgrade, refuse, off", s)
+}
+
+// Options configures a Client.
+type Options struct {
+	// Timeout is the timeout of a single request including reading the
+	// response body. It defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// Retries is the number of times a request is repeated after a 429 or 5xx
+	// status. Zero disables retries.
+	Retries int
+
+	// UserAgent overrides the User-Agent header.
+	UserAgent string
+
+	// HTTPS decides how plain http URLs and redirects to them are treated.
+	// By default they are upgraded to https. URLs of localhost are always
+	// requested as given.
+	HTTPS Policy
+
+	// Transport does the actual requests. It defaults to
+	// http.DefaultTransport and can be replaced to record, replay or fake
+	// responses.
+	Transport http.RoundTripper
+}
+
+// Client does the HTTP requests of the backends.
+type Client struct {
+	http      *http.Client
+	retries   int
+	userAgent string
+	https     Policy
+}
+
+// Default is the Client used if the context does not carry one.
+var Default = Options{Retries: DefaultRetries}.New()
+
+// New returns a Client configured by o.
+func (o Options) New() *Client {
+	c := &Client{
+		http:      &http.Client{Timeout: o.Timeout, Transport: o.Transport},
+		retries:   o.Retries,
+		userAgent: o.UserAgent,
+		https:     o.HTTPS,
+	}
+	if c.https != Allow {
+		c.http.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "https" && !loopback(req.URL) {
+				return fmt.Errorf("refusing redirect to insecure %s", req.URL.Redacted())
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		}
+	}
+	if c.http.Timeout == 0 {
+		c.http.Timeout = DefaultTimeout
+	}
+	if c.userAgent == "" {
+		c.userAgent = UserAgent
+	}
+	return c
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying c. Backends use the Client in
+// their context for all requests.
+func NewContext(ctx context.Context, c *Client) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the Client carried by ctx or Default.
+func FromContext(ctx context.Context) *Client {
+	if c, ok := ctx.Value(contextKey{}).(*Client); ok && c != nil {
+		return c
+	}
+	return Default
+}
+
+// Get requests uri with the Client carried by ctx. See Client.Get.
+func Get(ctx context.Context, uri string) ([]byte, error) {
+	return FromContext(ctx).Get(ctx, uri)
+}
+
+// statusError maps a non successful http status code to the matching iface
+// error class.
+func statusError(code int) error {
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return iface.ErrAuth
+	case code == http.StatusNotFound:
+		return iface.ErrUnknownLocation
+	case code == http.StatusTooManyRequests:
+		return iface.ErrQuota
+	default:
+		return iface.ErrUpstream
+	}
+}
+
+// loopback reports whether u points to the local machine. Plain http requests
+// to it, e.g. to a test server given as a backend base url, do not leave the
+// machine and are exempt from the https policy.
+func loopback(u *url.URL) bool {
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// retryable reports whether a request answered with status code is worth
+// repeating.
+func retryable(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// retryAfter returns the wait requested by the Retry-After header of res,
+// which is either a number of seconds or a date, or zero if there is none.
+func retryAfter(res *http.Response, now time.Time) time.Duration {
+	h := res.Header.Get("Retry-After")
+	if h == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(h); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// Get requests uri and returns the response body. If the server answers with a
+// status other than 200, the body is returned together with an error wrapping
+// the iface error class matching the status code. Requests answered with a 429
+// or 5xx status are repeated with an exponential backoff or after the wait
+// asked for by the server.
+func (c *Client) Get(ctx context.Context, uri string) ([]byte, error) {
+	backoff := minBackoff
+	for attempt := 0; ; attempt++ {
+		body, code, wait, err := c.get(ctx, uri)
+		if err != nil || code == http.StatusOK {
+			return body, err
+		}
+		err = fmt.Errorf("%w: unable to get (%s): http status %d", statusError(code), uri, code)
+		if !retryable(code) || attempt >= c.retries {
+			return body, err
+		}
+
+		if wait == 0 {
+			wait = backoff
+			backoff *= 2
+		}
+		if wait > maxBackoff {
+			return body, err
+		}
+		select {
+		case <-ctx.Done():
+			return body, err
+		case <-after(wait):
+		}
+	}
+}
+
+// get does a single request for uri. It returns the body, the status code and
+// the wait requested by the server.
+func (c *Client) get(ctx context.Context, uri string) ([]byte, int, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if req.URL.Scheme == "http" && !loopback(req.URL) {
+		switch c.https {
+		case Upgrade:
+			req.URL.Scheme = "https"
+		case Refuse:
+			return nil, 0, 0, fmt.Errorf("%w: refusing insecure request to %s, use https or -https-only off", iface.ErrUpstream, req.URL.Redacted())
+		}
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	// setting the header disables the transparent decompression of
+	// http.Transport, so gzip works the same with any RoundTripper
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("%w: unable to get (%s): %v", iface.ErrUpstream, uri, err)
+	}
+	defer res.Body.Close()
+
+	var r io.Reader = res.Body
+	if res.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(res.Body)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("%w: unable to read response body (%s): %v", iface.ErrUpstream, uri, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("%w: unable to read response body (%s): %v", iface.ErrUpstream, uri, err)
+	}
+	return body, res.StatusCode, retryAfter(res, time.Now()), nil
+}
@@ -0,0 +1,190 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// fakeTransport answers the requests with the given status codes in turn. A
+// code of 429 or 503 asks to retry after retryAfter if it is set.
+type fakeTransport struct {
+	codes      []int
+	retryAfter string
+	requests   []*http.Request
+}
+
+func (f *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	code := f.codes[len(f.requests)]
+	f.requests = append(f.requests, req)
+	res := &http.Response{
+		StatusCode: code,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(http.StatusText(code))),
+		Request:    req,
+	}
+	if f.retryAfter != "" && (code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable) {
+		res.Header.Set("Retry-After", f.retryAfter)
+	}
+	return res, nil
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 17, 6, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"0", 0},
+		{"120", 2 * time.Minute},
+		{"-5", 0},
+		{"Sat, 17 Oct 2026 06:00:30 GMT", 30 * time.Second},
+		{"Sat, 17 Oct 2026 05:00:00 GMT", 0},
+		{"soon", 0},
+	}
+
+	for _, test := range tests {
+		res := &http.Response{Header: http.Header{}}
+		if test.header != "" {
+			res.Header.Set("Retry-After", test.header)
+		}
+		if got := retryAfter(res, now); got != test.want {
+			t.Errorf("Retry-After %q: got %v, want %v", test.header, got, test.want)
+		}
+	}
+}
+
+func TestGetRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		codes      []int
+		retries    int
+		retryAfter string
+		requests   int
+		waits      []time.Duration
+		err        error
+	}{
+		{"ok", []int{200}, 0, "", 1, nil, nil},
+		{"backoff", []int{503, 502, 200}, DefaultRetries, "", 3, []time.Duration{minBackoff, 2 * minBackoff}, nil},
+		{"retries exhausted", []int{500, 500, 500, 500}, 3, "", 4, []time.Duration{minBackoff, 2 * minBackoff, 4 * minBackoff}, iface.ErrUpstream},
+		{"retries disabled", []int{503}, 0, "", 1, nil, iface.ErrUpstream},
+		{"retry after", []int{429, 200}, DefaultRetries, "3", 2, []time.Duration{3 * time.Second}, nil},
+		{"retry after too long", []int{429}, DefaultRetries, "3600", 1, nil, iface.ErrQuota},
+		{"not retryable", []int{404}, 0, "", 1, nil, iface.ErrUnknownLocation},
+		{"unauthorized", []int{401}, 0, "", 1, nil, iface.ErrAuth},
+	}
+
+	defer func() { after = time.After }()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var waits []time.Duration
+			after = func(d time.Duration) <-chan time.Time {
+				waits = append(waits, d)
+				return time.After(0)
+			}
+			transport := &fakeTransport{codes: test.codes, retryAfter: test.retryAfter}
+			c := Options{Retries: test.retries, Transport: transport}.New()
+
+			body, err := c.Get(context.Background(), "https://example.com/forecast")
+			if test.err == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.err != nil && !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if want := http.StatusText(test.codes[len(test.codes)-1]); string(body) != want {
+				t.Errorf("got body %q, want %q", body, want)
+			}
+			if len(transport.requests) != test.requests {
+				t.Errorf("got %d requests, want %d", len(transport.requests), test.requests)
+			}
+			if len(waits) != len(test.waits) {
+				t.Fatalf("waited %v, want %v", waits, test.waits)
+			}
+			for i := range waits {
+				if waits[i] != test.waits[i] {
+					t.Errorf("waited %v, want %v", waits, test.waits)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestGetHeaders(t *testing.T) {
+	transport := &fakeTransport{codes: []int{200}}
+	if _, err := (Options{Transport: transport}).New().Get(context.Background(), "https://example.com/"); err != nil {
+		t.Fatal(err)
+	}
+	req := transport.requests[0]
+	if got := req.Header.Get("User-Agent"); got != UserAgent {
+		t.Errorf("User-Agent %q, want %q", got, UserAgent)
+	}
+	if got := req.Header.Get("Accept-Encoding"); got != "gzip" {
+		t.Errorf("Accept-Encoding %q, want gzip", got)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		uri    string
+		scheme string
+		err    error
+	}{
+		{Upgrade, "http://example.com/forecast", "https", nil},
+		{Refuse, "http://example.com/forecast", "", iface.ErrUpstream},
+		{Allow, "http://example.com/forecast", "http", nil},
+		{Upgrade, "http://localhost:8080/forecast", "http", nil},
+		{Refuse, "http://127.0.0.1:8080/forecast", "http", nil},
+		{Refuse, "http://[::1]:8080/forecast", "http", nil},
+		{Upgrade, "http://localhost.example.com/forecast", "https", nil},
+	}
+
+	for _, test := range tests {
+		transport := &fakeTransport{codes: []int{200}}
+		_, err := Options{HTTPS: test.policy, Transport: transport}.New().Get(context.Background(), test.uri)
+		if test.err == nil && err != nil {
+			t.Errorf("policy %d, %s: unexpected error: %v", test.policy, test.uri, err)
+			continue
+		}
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("policy %d: got error %v, want %v", test.policy, err, test.err)
+			}
+			if len(transport.requests) != 0 {
+				t.Errorf("policy %d: insecure request sent", test.policy)
+			}
+			continue
+		}
+		if got := transport.requests[0].URL.Scheme; got != test.scheme {
+			t.Errorf("policy %d, %s: requested %s, want %s", test.policy, test.uri, got, test.scheme)
+		}
+	}
+}
+
+// redirectTransport redirects every https request to plain http.
+type redirectTransport struct{}
+
+func (redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}
+	if req.URL.Scheme == "https" {
+		res.StatusCode = http.StatusFound
+		res.Header.Set("Location", "http://example.com/plain")
+	}
+	return res, nil
+}
+
+func TestPolicyRedirect(t *testing.T) {
+	_, err := Options{Transport: redirectTransport{}}.New().Get(context.Background(), "https://example.com/")
+	if !errors.Is(err, iface.ErrUpstream) || !strings.Contains(err.Error(), "insecure") {
+		t.Errorf("got error %v, want a refused insecure redirect", err)
+	}
+}
@@ -0,0 +1,198 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// secretParams are the query parameters holding api keys. They are replaced
+// in recorded URLs, so recordings can be shared.
+var secretParams = []string{"appid", "key", "apikey", "api_key", "token"}
+
+// Exchange is a recorded request and its response.
+type Exchange struct {
+	Method string
+	URL    string
+	Status int
+	Header http.Header
+
+	// JSON holds the body if it is valid json, which keeps recordings
+	// readable. Other bodies are stored in Text.
+	JSON json.RawMessage `json:",omitempty"`
+	Text string          `json:",omitempty"`
+}
+
+// redact returns u with the values of secretParams replaced.
+func redact(u *url.URL) string {
+	q := u.Query()
+	for name := range q {
+		for _, s := range secretParams {
+			if strings.EqualFold(name, s) {
+				q.Set(name, "REDACTED")
+			}
+		}
+	}
+	r := *u
+	r.RawQuery = q.Encode()
+	return r.String()
+}
+
+// Recorder is an http.RoundTripper which saves every exchange as a json file
+// in Dir. Api keys in the query are redacted, but keys in the path, like the
+// ones of caiyunapp.com, are recorded as is.
+type Recorder struct {
+	Dir string
+
+	// Base is the RoundTripper doing the actual requests. It defaults to
+	// http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := r.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	res, err := base.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	// recordings are stored uncompressed, so they stay readable
+	if res.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(gz); err != nil {
+			return nil, err
+		}
+		res.Header.Del("Content-Encoding")
+		res.Header.Del("Content-Length")
+		res.ContentLength = int64(len(body))
+		res.Uncompressed = true
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	e := Exchange{Method: req.Method, URL: redact(req.URL), Status: res.StatusCode, Header: res.Header.Clone()}
+	if json.Valid(body) {
+		e.JSON = body
+	} else {
+		e.Text = string(body)
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(e); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(r.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(req.Method + " " + e.URL))
+	name := req.URL.Hostname() + "-" + hex.EncodeToString(sum[:4]) + ".json"
+	if err := os.WriteFile(filepath.Join(r.Dir, name), b.Bytes(), 0o644); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Replayer is an http.RoundTripper which answers requests with the exchanges
+// saved by a Recorder. A request is answered with the exchange of the same
+// URL or, if there is none, the first one with the same host and path. This
+// way URLs containing the current time can be replayed.
+type Replayer struct {
+	Dir string
+
+	once      sync.Once
+	exchanges []Exchange
+	err       error
+}
+
+func (r *Replayer) load() {
+	files, err := filepath.Glob(filepath.Join(r.Dir, "*.json"))
+	if err != nil {
+		r.err = err
+		return
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			r.err = err
+			return
+		}
+		var e Exchange
+		if err := json.Unmarshal(b, &e); err != nil {
+			r.err = fmt.Errorf("invalid recording %s: %v", f, err)
+			return
+		}
+		r.exchanges = append(r.exchanges, e)
+	}
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.once.Do(r.load)
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	want := redact(req.URL)
+	var match *Exchange
+	for i, e := range r.exchanges {
+		if e.Method == req.Method && e.URL == want {
+			match = &r.exchanges[i]
+			break
+		}
+	}
+	if match == nil {
+		for i, e := range r.exchanges {
+			u, err := url.Parse(e.URL)
+			if err == nil && e.Method == req.Method && u.Host == req.URL.Host && u.Path == req.URL.Path {
+				match = &r.exchanges[i]
+				break
+			}
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no recorded response for %s %s in %s", req.Method, want, r.Dir)
+	}
+
+	header := match.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	body := []byte(match.Text)
+	if match.JSON != nil {
+		body = match.JSON
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", match.Status, http.StatusText(match.Status)),
+		StatusCode:    match.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,185 @@
+package iface
+
+import (
+	"math"
+	"time"
+)
+
+// GroupDays splits slots into the calendar days of the time zone loc. The
+// slots must be ordered by time. The returned days start with the day of the
+// first slot, at most numdays are returned and Date is set to midnight.
+// Partial days at the end are kept.
+func GroupDays(slots []Cond, loc *time.Location, numdays int) (days []Day) {
+	if loc == nil {
+		loc = time.Local
+	}
+	for _, slot := range slots {
+		slot.Time = slot.Time.In(loc)
+		y, m, d := slot.Time.Date()
+		if len(days) == 0 || !days[len(days)-1].Date.Equal(time.Date(y, m, d, 0, 0, 0, 0, loc)) {
+			if len(days) >= numdays {
+				break
+			}
+			days = append(days, Day{Date: time.Date(y, m, d, 0, 0, 0, 0, loc)})
+		}
+		days[len(days)-1].Slots = append(days[len(days)-1].Slots, slot)
+	}
+	return days
+}
+
+// Regroup returns a copy of d with the slots of all days grouped into at most
+// numdays calendar days of d.TimeZone. Backends which do not know the time
+// zone of the location group their slots in UTC and the days are regrouped
+// once the time zone is known. Astronomy and air quality are kept for days
+// with the same date, the summaries are dropped because they cover other hours.
+func (d Data) Regroup(numdays int) Data {
+	var slots []Cond
+	for _, day := range d.Forecast {
+		slots = append(slots, day.Slots...)
+	}
+	days := GroupDays(slots, d.TimeZone.Location, numdays)
+	for i := range days {
+		y, m, dd := days[i].Date.Date()
+		for _, old := range d.Forecast {
+			if oy, om, od := old.Date.Date(); oy == y && om == m && od == dd {
+				days[i].Astronomy, days[i].AirQuality = old.Astronomy, old.AirQuality
+				break
+			}
+		}
+	}
+	d.Forecast = days
+	return d
+}
+
+// Resample returns slots at every multiple of interval of wall clock time since
+// midnight between the first and the last of the given slots, which must be
+// ordered by time. On days with a daylight saving time change the slots stay
+// at the same clock times, so a wall clock time which is skipped is left out
+// and one which repeats is only returned once. Numeric fields are interpolated
+// linearly between the two surrounding slots, the wind direction along the
+// shorter arc. All other fields and numeric fields missing in one of the
+// surrounding slots are taken from the closer one.
+func Resample(slots []Cond, interval time.Duration) (ret []Cond) {
+	if len(slots) == 0 || interval <= 0 {
+		return slots
+	}
+	first, last := slots[0].Time, slots[len(slots)-1].Time
+	y, m, d := first.Date()
+
+	i := 0
+	var prev time.Time
+	for k := time.Duration(0); ; k++ {
+		// time.Date normalizes the wall clock time after midnight
+		wall := k * interval
+		t := time.Date(y, m, d, 0, 0, int(wall/time.Second), int(wall%time.Second), first.Location())
+		if t.After(last) {
+			break
+		}
+		if t.Before(first) || !t.After(prev) {
+			continue
+		}
+		prev = t
+		for i+1 < len(slots) && !slots[i+1].Time.After(t) {
+			i++
+		}
+		if i+1 == len(slots) || slots[i].Time.Equal(t) {
+			slot := slots[i]
+			slot.Time = t
+			ret = append(ret, slot)
+			continue
+		}
+		ret = append(ret, interpolate(slots[i], slots[i+1], t))
+	}
+	return ret
+}
+
+// interpolate returns the condition at t between a and b.
+func interpolate(a, b Cond, t time.Time) Cond {
+	f := float32(t.Sub(a.Time)) / float32(b.Time.Sub(a.Time))
+	ret := a
+	if f > 0.5 {
+		ret = b
+	}
+	ret.Time = t
+
+	float := func(x, y *float32) *float32 {
+		if x == nil || y == nil {
+			if f > 0.5 {
+				return y
+			}
+			return x
+		}
+		v := *x + (*y-*x)*f
+		return &v
+	}
+	integer := func(x, y *int) *int {
+		if x == nil || y == nil {
+			if f > 0.5 {
+				return y
+			}
+			return x
+		}
+		v := int(math.Round(float64(float32(*x) + float32(*y-*x)*f)))
+		return &v
+	}
+
+	ret.TempC = float(a.TempC, b.TempC)
+	ret.FeelsLikeC = float(a.FeelsLikeC, b.FeelsLikeC)
+	ret.ChanceOfRainPercent = integer(a.ChanceOfRainPercent, b.ChanceOfRainPercent)
+	ret.PrecipM = float(a.PrecipM, b.PrecipM)
+	ret.VisibleDistM = float(a.VisibleDistM, b.VisibleDistM)
+	ret.WindspeedKmph = float(a.WindspeedKmph, b.WindspeedKmph)
+	ret.WindGustKmph = float(a.WindGustKmph, b.WindGustKmph)
+	ret.Humidity = integer(a.Humidity, b.Humidity)
+	ret.PressureHPa = float(a.PressureHPa, b.PressureHPa)
+	ret.DewPointC = float(a.DewPointC, b.DewPointC)
+	ret.CloudCoverPercent = integer(a.CloudCoverPercent, b.CloudCoverPercent)
+	ret.UVIndex = float(a.UVIndex, b.UVIndex)
+	ret.SnowfallM = float(a.SnowfallM, b.SnowfallM)
+
+	if a.WinddirDegree != nil && b.WinddirDegree != nil {
+		delta := (*b.WinddirDegree-*a.WinddirDegree+540)%360 - 180
+		v := (*a.WinddirDegree + int(math.Round(float64(float32(delta)*f))) + 360) % 360
+		ret.WinddirDegree = &v
+	}
+	return ret
+}
+
+// DropPast returns a copy of d without the days which ended before now and
+// without the slots which ended before now. A slot ends when the next slot
+// starts, the last slot of a day at the end of the day.
+func (d Data) DropPast(now time.Time) Data {
+	conds := func(cs []Cond, end time.Time) (ret []Cond) {
+		for i, c := range cs {
+			next := end
+			if i+1 < len(cs) {
+				next = cs[i+1].Time
+			}
+			if next.After(now) {
+				ret = append(ret, c)
+			}
+		}
+		return ret
+	}
+
+	var nowcastEnd time.Time
+	if n := len(d.Nowcast); n > 0 {
+		nowcastEnd = d.Nowcast[n-1].Time
+		if n > 1 {
+			nowcastEnd = nowcastEnd.Add(d.Nowcast[n-1].Time.Sub(d.Nowcast[n-2].Time))
+		}
+	}
+	d.Nowcast = conds(d.Nowcast, nowcastEnd)
+
+	var days []Day
+	for _, day := range d.Forecast {
+		end := day.Date.AddDate(0, 0, 1)
+		if !end.After(now) {
+			continue
+		}
+		day.Slots = conds(day.Slots, end)
+		days = append(days, day)
+	}
+	d.Forecast = days
+	return d
+}
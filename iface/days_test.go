@@ -0,0 +1,202 @@
+package iface
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// hourly returns n hourly slots starting at start with the hour index as
+// temperature.
+func hourly(start time.Time, n int) (ret []Cond) {
+	for i := 0; i < n; i++ {
+		temp := float32(i)
+		ret = append(ret, Cond{Time: start.Add(time.Duration(i) * time.Hour), TempC: &temp})
+	}
+	return ret
+}
+
+func TestRegroup(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	// 20:00 UTC on 17 Oct to 19:00 UTC on 19 Oct, grouped in UTC
+	slots := hourly(time.Date(2026, 10, 17, 20, 0, 0, 0, time.UTC), 48)
+	d := Data{Forecast: GroupDays(slots, time.UTC, 3)}
+	sunrise := time.Date(2026, 10, 18, 5, 40, 0, 0, time.UTC)
+	d.Forecast[1].Astronomy.Sunrise = sunrise
+	d.Forecast[1].Summary.MaxTempC = slots[0].TempC
+
+	d.TimeZone = TimeZone{berlin}
+	got := d.Regroup(3)
+
+	if len(got.Forecast) != 3 {
+		t.Fatalf("got %d days, want 3", len(got.Forecast))
+	}
+	wantSlots := []int{2, 24, 22}
+	for i, day := range got.Forecast {
+		if want := time.Date(2026, 10, 17+i, 0, 0, 0, 0, berlin); !day.Date.Equal(want) {
+			t.Errorf("day %d starts %v, want %v", i, day.Date, want)
+		}
+		if len(day.Slots) != wantSlots[i] {
+			t.Errorf("day %d has %d slots, want %d", i, len(day.Slots), wantSlots[i])
+		}
+	}
+	if !got.Forecast[1].Astronomy.Sunrise.Equal(sunrise) {
+		t.Errorf("astronomy of 18 Oct not kept")
+	}
+	if got.Forecast[1].Summary.MaxTempC != nil {
+		t.Errorf("summary of the UTC day kept")
+	}
+	if len(d.Forecast[0].Slots) != 4 {
+		t.Errorf("Regroup modified the original data")
+	}
+}
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skip(err)
+	}
+	return loc
+}
+
+func TestGroupDays(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	tests := []struct {
+		name    string
+		start   time.Time
+		n       int
+		numdays int
+		want    []int
+	}{
+		{"starts at midnight", time.Date(2026, 10, 17, 0, 0, 0, 0, berlin), 48, 3, []int{24, 24}},
+		{"ends at midnight", time.Date(2026, 10, 17, 22, 0, 0, 0, berlin), 3, 3, []int{2, 1}},
+		{"utc slots", time.Date(2026, 10, 17, 21, 0, 0, 0, time.UTC), 3, 3, []int{1, 2}},
+		{"numdays", time.Date(2026, 10, 17, 12, 0, 0, 0, berlin), 72, 2, []int{12, 24}},
+		{"spring forward", time.Date(2026, 3, 29, 0, 0, 0, 0, berlin), 24, 2, []int{23, 1}},
+		{"fall back", time.Date(2026, 10, 25, 0, 0, 0, 0, berlin), 26, 2, []int{25, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			days := GroupDays(hourly(test.start, test.n), berlin, test.numdays)
+			if len(days) != len(test.want) {
+				t.Fatalf("got %d days, want %d", len(days), len(test.want))
+			}
+			for i, day := range days {
+				if len(day.Slots) != test.want[i] {
+					t.Errorf("day %d has %d slots, want %d", i, len(day.Slots), test.want[i])
+				}
+				if day.Date.Hour() != 0 || day.Date.Location() != berlin {
+					t.Errorf("day %d starts %v, want midnight in Berlin", i, day.Date)
+				}
+				for _, slot := range day.Slots {
+					if y, m, d := slot.Time.Date(); y != day.Date.Year() || m != day.Date.Month() || d != day.Date.Day() {
+						t.Errorf("slot %v in day %v", slot.Time, day.Date)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestResample(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	tests := []struct {
+		name     string
+		start    time.Time
+		step     time.Duration
+		n        int
+		interval time.Duration
+		want     []string
+	}{
+		{"coarser", time.Date(2026, 10, 17, 0, 0, 0, 0, berlin), time.Hour, 7, 3 * time.Hour, []string{"00:00", "03:00", "06:00"}},
+		{"finer", time.Date(2026, 10, 17, 6, 0, 0, 0, berlin), 6 * time.Hour, 2, 2 * time.Hour, []string{"06:00", "08:00", "10:00", "12:00"}},
+		{"off grid start", time.Date(2026, 10, 17, 22, 30, 0, 0, berlin), time.Hour, 3, time.Hour, []string{"23:00", "00:00"}},
+		{"spring forward", time.Date(2026, 3, 29, 0, 0, 0, 0, berlin), time.Hour, 12, 3 * time.Hour, []string{"00:00", "03:00", "06:00", "09:00", "12:00"}},
+		{"fall back", time.Date(2026, 10, 25, 0, 0, 0, 0, berlin), time.Hour, 14, 3 * time.Hour, []string{"00:00", "03:00", "06:00", "09:00", "12:00"}},
+		{"fall back hourly", time.Date(2026, 10, 25, 1, 0, 0, 0, berlin), time.Hour, 4, time.Hour, []string{"01:00", "02:00", "03:00"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var slots []Cond
+			for i, slot := range hourly(test.start, test.n) {
+				slot.Time = test.start.Add(time.Duration(i) * test.step)
+				slots = append(slots, slot)
+			}
+			var got []string
+			for _, slot := range Resample(slots, test.interval) {
+				got = append(got, slot.Time.Format("15:04"))
+			}
+			if strings.Join(got, " ") != strings.Join(test.want, " ") {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestResampleInterpolates(t *testing.T) {
+	start := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	t0, t1 := float32(10), float32(16)
+	d0, d1 := 350, 20
+	slots := []Cond{
+		{Time: start, TempC: &t0, WinddirDegree: &d0, Code: CodeSunny},
+		{Time: start.Add(3 * time.Hour), TempC: &t1, WinddirDegree: &d1, Code: CodeCloudy},
+	}
+	got := Resample(slots, time.Hour)
+	if len(got) != 4 {
+		t.Fatalf("got %d slots, want 4", len(got))
+	}
+	if *got[1].TempC != 12 || *got[2].TempC != 14 {
+		t.Errorf("temperatures %v, %v, want 12, 14", *got[1].TempC, *got[2].TempC)
+	}
+	if *got[1].WinddirDegree != 0 {
+		t.Errorf("wind direction %v, want 0 along the shorter arc", *got[1].WinddirDegree)
+	}
+	if got[1].Code != CodeSunny || got[2].Code != CodeCloudy {
+		t.Errorf("codes %v, %v, want the ones of the closer slot", got[1].Code, got[2].Code)
+	}
+}
+
+func TestDropPast(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	start := time.Date(2026, 10, 17, 0, 0, 0, 0, berlin)
+	d := Data{
+		Forecast: GroupDays(hourly(start, 72), berlin, 3),
+		Nowcast:  hourly(start.Add(23*time.Hour), 2),
+	}
+	tests := []struct {
+		name    string
+		now     time.Time
+		days    int
+		slots   int
+		nowcast int
+	}{
+		{"before", start.Add(-time.Hour), 3, 24, 2},
+		{"within the first slot", start.Add(30 * time.Minute), 3, 24, 2},
+		{"end of the first slot", start.Add(time.Hour), 3, 23, 2},
+		{"last slot of the day", start.Add(23*time.Hour + 30*time.Minute), 3, 1, 2},
+		{"midnight", start.AddDate(0, 0, 1), 2, 24, 1},
+		{"after the nowcast", start.Add(25 * time.Hour), 2, 23, 0},
+		{"after the forecast", start.AddDate(0, 0, 3), 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := d.DropPast(test.now)
+			if len(got.Forecast) != test.days {
+				t.Fatalf("got %d days, want %d", len(got.Forecast), test.days)
+			}
+			if test.days > 0 && len(got.Forecast[0].Slots) != test.slots {
+				t.Errorf("first day has %d slots, want %d", len(got.Forecast[0].Slots), test.slots)
+			}
+			if len(got.Nowcast) != test.nowcast {
+				t.Errorf("got %d nowcast slots, want %d", len(got.Nowcast), test.nowcast)
+			}
+		})
+	}
+	if len(d.Forecast) != 3 || len(d.Forecast[0].Slots) != 24 {
+		t.Errorf("DropPast modified the original data")
+	}
+}
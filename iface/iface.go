@@ -1,6 +1,9 @@
 package iface
 
 import (
+	"context"
+	"errors"
+	"io"
 	"log"
 	"time"
 )
@@ -29,6 +32,43 @@ const (
 	CodeVeryCloudy
 )
 
+// PrecipType is the kind of precipitation. The zero value means the type is
+// unknown.
+type PrecipType int
+
+const (
+	PrecipUnknown PrecipType = iota
+	PrecipNone
+	PrecipRain
+	PrecipDrizzle
+	PrecipFreezingRain
+	PrecipSleet
+	PrecipSnow
+	PrecipHail
+)
+
+type AirQuality struct {
+	// AQIUS is the air quality index on the scale of the US EPA. It must be in
+	// the range [0, 500].
+	AQIUS *int
+
+	// AQIChina is the air quality index on the scale of the chinese ministry
+	// of environmental protection. It must be in the range [0, 500].
+	AQIChina *int
+
+	// PM25 is the concentration of particles smaller than 2.5µm in µg/m³.
+	PM25 *float32
+
+	// PM10 is the concentration of particles smaller than 10µm in µg/m³.
+	PM10 *float32
+
+	// O3 is the concentration of ozone in µg/m³.
+	O3 *float32
+
+	// NO2 is the concentration of nitrogen dioxide in µg/m³.
+	NO2 *float32
+}
+
 type Cond struct {
 	// Time is the time, where this weather condition applies.
 	Time time.Time
@@ -75,13 +115,109 @@ type Cond struct {
 
 	// Humidity is the *relative* humidity and must be in [0, 100].
 	Humidity *int
+
+	// PressureHPa is the air pressure reduced to mean sea level in hectopascal.
+	// It must be > 0.
+	PressureHPa *float32
+
+	// DewPointC is the dew point in degrees celsius.
+	DewPointC *float32
+
+	// CloudCoverPercent is the fraction of the sky covered by clouds. It must
+	// be in the range [0, 100].
+	CloudCoverPercent *int
+
+	// UVIndex is the ultraviolet index. It must be >= 0.
+	UVIndex *float32
+
+	// SnowfallM is the amount of fresh snow in meters(!) per hour. Must be >= 0.
+	SnowfallM *float32
+
+	// PrecipType is the kind of precipitation and must be one of the
+	// PrecipType constants.
+	PrecipType PrecipType
+
+	// AirQuality holds the pollution levels. It is nil if unknown.
+	AirQuality *AirQuality
+
+	// Spread holds the lowest and highest values forecast by the members of
+	// an ensemble if the condition is their median. It is nil otherwise.
+	Spread *Spread `json:",omitempty"`
+}
+
+// Spread is the range of the values several providers forecast for the same
+// time. Fields are nil if no provider forecast them.
+type Spread struct {
+	Min Cond
+	Max Cond
 }
 
+// MoonPhase is one of the eight principal phases of the moon. The zero value
+// means the phase is unknown.
+type MoonPhase int
+
+const (
+	MoonPhaseUnknown MoonPhase = iota
+	MoonNew
+	MoonWaxingCrescent
+	MoonFirstQuarter
+	MoonWaxingGibbous
+	MoonFull
+	MoonWaningGibbous
+	MoonLastQuarter
+	MoonWaningCrescent
+)
+
 type Astro struct {
 	Moonrise time.Time
 	Moonset  time.Time
 	Sunrise  time.Time
 	Sunset   time.Time
+
+	// MoonPhase is the phase of the moon at noon and must be one of the
+	// MoonPhase constants.
+	MoonPhase MoonPhase
+
+	// MoonAge is the time since the last new moon. Only valid if MoonPhase is
+	// known.
+	MoonAge time.Duration
+
+	// MoonIllumination is the illuminated fraction of the moon in percent. Only
+	// valid if MoonPhase is known.
+	MoonIllumination float32
+
+	// SolarNoon is the time the sun reaches its highest point.
+	SolarNoon time.Time
+
+	// Twilight begins at dawn and ends at dusk when the sun is 6 (civil), 12
+	// (nautical) or 18 (astronomical) degrees below the horizon.
+	CivilDawn        time.Time
+	CivilDusk        time.Time
+	NauticalDawn     time.Time
+	NauticalDusk     time.Time
+	AstronomicalDawn time.Time
+	AstronomicalDusk time.Time
+
+	// DayLength is the time between sunrise and sunset. It is 24 hours during
+	// polar day.
+	DayLength time.Duration
+}
+
+// Summary holds the extremes and totals of a Day. Fields are nil if unknown.
+type Summary struct {
+	MinTempC      *float32
+	MaxTempC      *float32
+	MinFeelsLikeC *float32
+	MaxFeelsLikeC *float32
+
+	// PrecipSumM is the total precipitation of the day in meters(!).
+	PrecipSumM *float32
+
+	MaxWindGustKmph        *float32
+	MaxChanceOfRainPercent *int
+
+	// Code is the dominant weather of the day.
+	Code WeatherCode
 }
 
 type Day struct {
@@ -94,6 +230,45 @@ type Day struct {
 
 	// Astronomy contains planetary data.
 	Astronomy Astro
+
+	// Summary holds the extremes and totals of the day.
+	Summary Summary
+
+	// AirQuality holds the highest pollution levels of the day. It is nil if
+	// unknown.
+	AirQuality *AirQuality
+}
+
+// AlertSeverity is the severity level of a weather alert. The zero value means
+// the severity is unknown.
+type AlertSeverity int
+
+const (
+	SeverityUnknown AlertSeverity = iota
+	SeverityMinor
+	SeverityModerate
+	SeveritySevere
+	SeverityExtreme
+)
+
+type Alert struct {
+	// Title is a short headline of the alert.
+	Title string
+
+	// Description is the full text of the alert.
+	Description string
+
+	// Severity must be one of the AlertSeverity constants.
+	Severity AlertSeverity
+
+	// Onset is the time from which on the alert applies. It is zero if unknown.
+	Onset time.Time
+
+	// Expiry is the time at which the alert ends. It is zero if unknown.
+	Expiry time.Time
+
+	// Source is the name of the agency which issued the alert.
+	Source string
 }
 
 type LatLon struct {
@@ -104,8 +279,46 @@ type LatLon struct {
 type Data struct {
 	Current  Cond
 	Forecast []Day
+
+	// Location is the human readable name of the place the forecast is for.
+	// Backends may leave it empty if the provider does not name the place.
 	Location string
 	GeoLoc   *LatLon
+
+	// TimeZone is the time zone of the location. Frontends show all times in
+	// it. Backends leave it empty if the provider does not report it and group
+	// the forecast in UTC, the wego client then looks it up from GeoLoc.
+	TimeZone TimeZone
+
+	// Alerts are the weather warnings currently issued for the location.
+	Alerts []Alert
+
+	// Nowcast is a short term precipitation forecast for the next hours in a
+	// resolution of a few minutes. The slots should be ordered by their Time
+	// and usually only carry the precipitation fields.
+	Nowcast []Cond
+
+	// Fetched is the time the data was fetched from the provider. It is zero
+	// if unknown.
+	Fetched time.Time
+
+	// Source is the name of the backend which delivered the data if it was
+	// picked from several ones, or says how the data of several backends was
+	// merged. Backends set it to credit the provider if its terms ask for it.
+	Source string
+
+	// Members holds the data of every backend if it was merged from several
+	// ones. Their Source names the backend.
+	Members []Data `json:",omitempty"`
+
+	// PrecisionKm is the size of the grid cells the coordinates were snapped
+	// to before querying the provider. It is zero if the exact location was
+	// queried.
+	PrecisionKm float64
+
+	// Stale is set if the provider could not be queried and older data is
+	// shown instead. Frontends should point out how old the data is.
+	Stale bool
 }
 
 type UnitSystem int
@@ -164,14 +377,47 @@ func (u UnitSystem) Distance(distM float32) (res float32, unit string) {
 	return
 }
 
+var (
+	// ErrUnknownLocation is returned by a backend if the requested location
+	// could not be found.
+	ErrUnknownLocation = errors.New("unknown location")
+
+	// ErrAuth is returned by a backend if the api key is missing or was
+	// rejected by the provider.
+	ErrAuth = errors.New("authentication failed")
+
+	// ErrQuota is returned by a backend if the provider refused the request
+	// because a rate limit or quota was exceeded.
+	ErrQuota = errors.New("quota exceeded")
+
+	// ErrUpstream is returned by a backend if the provider could not be reached
+	// or answered with an unexpected error.
+	ErrUpstream = errors.New("upstream error")
+
+	// ErrParse is returned by a backend if the response of the provider could
+	// not be understood.
+	ErrParse = errors.New("malformed response")
+)
+
 type Backend interface {
 	Setup()
-	Fetch(location string, numdays int) Data
+
+	// SupportedLocations returns the kinds of locations Fetch understands.
+	SupportedLocations() LocationKind
+
+	// Fetch retrieves the current weather and a forecast for numdays days at
+	// location. Errors should wrap one of the Err* values above, so callers can
+	// tell the different failure classes apart with errors.Is.
+	Fetch(ctx context.Context, location Location, numdays int) (Data, error)
 }
 
 type Frontend interface {
 	Setup()
-	Render(weather Data, unitSystem UnitSystem)
+
+	// Render writes weather to w using unitSystem. Frontends may emit ANSI
+	// color sequences; whether they reach the terminal is up to the caller,
+	// which can filter them out, e.g. if w is not a TTY.
+	Render(w io.Writer, weather Data, unitSystem UnitSystem) error
 }
 
 var (
@@ -0,0 +1,207 @@
+package iface
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LocationKind is a set of location forms. Backends use it to declare which
+// forms they understand.
+type LocationKind int
+
+const (
+	// LocationName is a free text place name like "New York".
+	LocationName LocationKind = 1 << iota
+	// LocationCoords is a "latitude,longitude" pair like "40.748,-73.985".
+	LocationCoords
+	// LocationPostal is a postal code with an optional ISO 3166 country code
+	// like "10001" or "10001,US".
+	LocationPostal
+	// LocationAirport is a four letter ICAO airport code like "KJFK".
+	LocationAirport
+
+	// LocationAny contains all location kinds.
+	LocationAny = LocationName | LocationCoords | LocationPostal | LocationAirport
+)
+
+var locationKinds = []struct {
+	kind    LocationKind
+	name    string
+	example string
+}{
+	{LocationCoords, "coordinate", "`59.329,18.068` (latitude,longitude)"},
+	{LocationName, "place name", "`Stockholm`"},
+	{LocationPostal, "postal code", "`10001,US` (postal code,country)"},
+	{LocationAirport, "airport", "`ESSA` (ICAO airport code)"},
+}
+
+// String returns the human readable names of the kinds in k.
+func (k LocationKind) String() string {
+	var names []string
+	for _, lk := range locationKinds {
+		if k&lk.kind != 0 {
+			names = append(names, lk.name)
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+// Location is a parsed location query. Only the fields matching Kind are set.
+type Location struct {
+	// Kind is exactly one of the LocationKind constants.
+	Kind LocationKind
+
+	// Raw is the location as given by the user.
+	Raw string
+
+	// LatLon holds the coordinates of a LocationCoords.
+	LatLon LatLon
+
+	// Name holds the place name of a LocationName.
+	Name string
+
+	// Postal holds the postal code of a LocationPostal.
+	Postal string
+
+	// Country is the optional upper case ISO 3166 country code of a
+	// LocationPostal.
+	Country string
+
+	// ICAO is the upper case airport code of a LocationAirport.
+	ICAO string
+}
+
+var (
+	coordsRegexp = regexp.MustCompile(`^(-?[0-9]+(?:\.[0-9]+)?)\s*,\s*(-?[0-9]+(?:\.[0-9]+)?)$`)
+	// numeric postal codes like "10001", "114 55" or "1000-001"
+	postalRegexp = regexp.MustCompile(`^([0-9]{3,}(?:[ -][0-9]{2,})?)\s*(?:,\s*([A-Za-z]{2}))?$`)
+	// alphanumeric postal codes like "K1A 0B6,CA" only with a country
+	postalCountryRegexp = regexp.MustCompile(`^([0-9A-Za-z]{2,4}[ -]?[0-9A-Za-z]{2,4})\s*,\s*([A-Za-z]{2})$`)
+	ukPostalRegexp      = regexp.MustCompile(`^(?i)[A-Z]{1,2}[0-9][A-Z0-9]? ?[0-9][A-Z]{2}$`)
+	airportRegexp       = regexp.MustCompile(`^[A-Z]{4}$`)
+)
+
+// ParseLocation parses s into a Location. Coordinates are "latitude,longitude"
+// pairs in decimal degrees. Postal codes are numeric codes like "10001" or
+// British postcodes like "SW1A 1AA", both optionally followed by a comma and a
+// country code, or any other code containing a digit followed by a country
+// code like "K1A 0B6,CA". Four upper case letters are ICAO airport codes and
+// everything else is a place name. Use Location.For to treat postal and
+// airport codes as place names if a backend does not support them.
+func ParseLocation(s string) (Location, error) {
+	s = strings.TrimSpace(s)
+	loc := Location{Raw: s}
+	if s == "" {
+		return loc, fmt.Errorf("%w: empty location", ErrUnknownLocation)
+	}
+
+	if m := coordsRegexp.FindStringSubmatch(s); m != nil {
+		lat, _ := strconv.ParseFloat(m[1], 32)
+		lon, _ := strconv.ParseFloat(m[2], 32)
+		if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return loc, fmt.Errorf("%w: coordinates `%s` out of range, latitude must be in [-90, 90] and longitude in [-180, 180]", ErrUnknownLocation, s)
+		}
+		loc.Kind = LocationCoords
+		loc.LatLon = LatLon{Latitude: float32(lat), Longitude: float32(lon)}
+	} else if m := postalRegexp.FindStringSubmatch(s); m != nil {
+		loc.Kind = LocationPostal
+		loc.Postal = m[1]
+		loc.Country = strings.ToUpper(m[2])
+	} else if m := postalCountryRegexp.FindStringSubmatch(s); m != nil && strings.ContainsAny(m[1], "0123456789") {
+		loc.Kind = LocationPostal
+		loc.Postal = strings.ToUpper(m[1])
+		loc.Country = strings.ToUpper(m[2])
+	} else if ukPostalRegexp.MatchString(s) {
+		loc.Kind = LocationPostal
+		loc.Postal = strings.ToUpper(s)
+		loc.Country = "GB"
+	} else if airportRegexp.MatchString(s) {
+		loc.Kind = LocationAirport
+		loc.ICAO = s
+	} else {
+		loc.Kind = LocationName
+		loc.Name = s
+	}
+	return loc, nil
+}
+
+// For returns l as a place name if supported contains place names but not the
+// kind of l. Postal and airport codes can be place names as well, e.g. "ROME"
+// or "OSLO", and the backend may still find them by name. Otherwise l is
+// returned unchanged.
+func (l Location) For(supported LocationKind) Location {
+	if l.Kind&supported != 0 || supported&LocationName == 0 || (l.Kind != LocationPostal && l.Kind != LocationAirport) {
+		return l
+	}
+	return Location{Kind: LocationName, Raw: l.Raw, Name: l.Raw}
+}
+
+// Snap returns the point of a grid with cells of about km × km closest to l.
+// The cells get wider in degrees of longitude towards the poles, so they keep
+// their size. Snapping hides the exact position when the coordinates are sent
+// to a weather provider.
+func (l LatLon) Snap(km float64) LatLon {
+	if km <= 0 {
+		return l
+	}
+	// round away the floating point noise of the multiplication, so the
+	// snapped coordinates print short
+	round := func(x float64) float32 {
+		return float32(math.Round(x*1e4) / 1e4)
+	}
+
+	step := km / 111.2 // one degree of latitude is about 111.2 km
+	lat := math.Max(-90, math.Min(90, math.Round(float64(l.Latitude)/step)*step))
+	lonStep := step / math.Max(math.Cos(lat*math.Pi/180), 0.01)
+	lon := math.Round(float64(l.Longitude)/lonStep) * lonStep
+	if lon > 180 {
+		lon -= 360
+	} else if lon < -180 {
+		lon += 360
+	}
+	return LatLon{Latitude: round(lat), Longitude: round(lon)}
+}
+
+// Coords returns the coordinates of a LocationCoords formatted with the
+// shortest representation, e.g. "59.329" and "18.068".
+func (l Location) Coords() (lat, lon string) {
+	return strconv.FormatFloat(float64(l.LatLon.Latitude), 'f', -1, 32), strconv.FormatFloat(float64(l.LatLon.Longitude), 'f', -1, 32)
+}
+
+// String returns the location in the form accepted by ParseLocation.
+func (l Location) String() string {
+	switch l.Kind {
+	case LocationCoords:
+		lat, lon := l.Coords()
+		return lat + "," + lon
+	case LocationPostal:
+		if l.Country != "" {
+			return l.Postal + "," + l.Country
+		}
+		return l.Postal
+	case LocationAirport:
+		return l.ICAO
+	case LocationName:
+		return l.Name
+	}
+	return l.Raw
+}
+
+// Check returns an error wrapping ErrUnknownLocation which suggests the
+// supported forms if the kind of l is not in supported. backend is the name of
+// the backend used in the message.
+func (l Location) Check(backend string, supported LocationKind) error {
+	if l.Kind&supported != 0 {
+		return nil
+	}
+	var examples []string
+	for _, lk := range locationKinds {
+		if supported&lk.kind != 0 {
+			examples = append(examples, lk.example)
+		}
+	}
+	return fmt.Errorf("%w: the %s backend does not support %s locations like `%s`.\nTry a %s location instead, for example %s", ErrUnknownLocation, backend, l.Kind, l, supported, strings.Join(examples, " or "))
+}
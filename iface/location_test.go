@@ -0,0 +1,80 @@
+package iface
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Location
+	}{
+		{"59.329,18.068", Location{Kind: LocationCoords, LatLon: LatLon{Latitude: 59.329, Longitude: 18.068}}},
+		{" -33.87 , 151.21 ", Location{Kind: LocationCoords, LatLon: LatLon{Latitude: -33.87, Longitude: 151.21}}},
+		{"New York", Location{Kind: LocationName, Name: "New York"}},
+		{"Paris, FR", Location{Kind: LocationName, Name: "Paris, FR"}},
+		{"3rd Street", Location{Kind: LocationName, Name: "3rd Street"}},
+		{"1600 Pennsylvania Ave", Location{Kind: LocationName, Name: "1600 Pennsylvania Ave"}},
+		{"10001", Location{Kind: LocationPostal, Postal: "10001"}},
+		{"10001,us", Location{Kind: LocationPostal, Postal: "10001", Country: "US"}},
+		{"114 55, SE", Location{Kind: LocationPostal, Postal: "114 55", Country: "SE"}},
+		{"1000-001,PT", Location{Kind: LocationPostal, Postal: "1000-001", Country: "PT"}},
+		{"SW1A 1AA", Location{Kind: LocationPostal, Postal: "SW1A 1AA", Country: "GB"}},
+		{"ec1a1bb", Location{Kind: LocationPostal, Postal: "EC1A1BB", Country: "GB"}},
+		{"K1A 0B6,CA", Location{Kind: LocationPostal, Postal: "K1A 0B6", Country: "CA"}},
+		{"KJFK", Location{Kind: LocationAirport, ICAO: "KJFK"}},
+		{"ROME", Location{Kind: LocationAirport, ICAO: "ROME"}},
+		{"Rome", Location{Kind: LocationName, Name: "Rome"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			got, err := ParseLocation(test.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.want.Raw = got.Raw
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseLocationErrors(t *testing.T) {
+	for _, in := range []string{"", "  ", "91,0", "0,181"} {
+		if _, err := ParseLocation(in); !errors.Is(err, ErrUnknownLocation) {
+			t.Errorf("ParseLocation(%q) = %v, want ErrUnknownLocation", in, err)
+		}
+	}
+}
+
+func TestLocationFor(t *testing.T) {
+	tests := []struct {
+		in        string
+		supported LocationKind
+		want      LocationKind
+	}{
+		{"ROME", LocationCoords | LocationName, LocationName},
+		{"ROME", LocationAirport | LocationName, LocationAirport},
+		{"ROME", LocationCoords, LocationAirport},
+		{"10001,US", LocationName, LocationName},
+		{"10001,US", LocationName | LocationPostal, LocationPostal},
+		{"59.3,18.1", LocationName, LocationCoords},
+	}
+
+	for _, test := range tests {
+		loc, err := ParseLocation(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := loc.For(test.supported)
+		if got.Kind != test.want {
+			t.Errorf("%q.For(%v) is a %v, want a %v", test.in, test.supported, got.Kind, test.want)
+		}
+		if got.Kind == LocationName && got.Name != test.in {
+			t.Errorf("%q.For(%v) is named %q", test.in, test.supported, got.Name)
+		}
+	}
+}
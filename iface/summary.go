@@ -0,0 +1,86 @@
+package iface
+
+import "time"
+
+// Summarize computes the Summary of a day from its slots, which must be
+// ordered by time. The precipitation of every slot is taken to last until the
+// next slot, the one of the last slot as long as the interval before it.
+func Summarize(slots []Cond) (ret Summary) {
+	min := func(cur **float32, v *float32) {
+		if v != nil && (*cur == nil || *v < **cur) {
+			x := *v
+			*cur = &x
+		}
+	}
+	max := func(cur **float32, v *float32) {
+		if v != nil && (*cur == nil || *v > **cur) {
+			x := *v
+			*cur = &x
+		}
+	}
+
+	codes := map[WeatherCode]int{}
+	for i, slot := range slots {
+		min(&ret.MinTempC, slot.TempC)
+		max(&ret.MaxTempC, slot.TempC)
+		min(&ret.MinFeelsLikeC, slot.FeelsLikeC)
+		max(&ret.MaxFeelsLikeC, slot.FeelsLikeC)
+		max(&ret.MaxWindGustKmph, slot.WindGustKmph)
+		if c := slot.ChanceOfRainPercent; c != nil && (ret.MaxChanceOfRainPercent == nil || *c > *ret.MaxChanceOfRainPercent) {
+			x := *c
+			ret.MaxChanceOfRainPercent = &x
+		}
+
+		if slot.Code != CodeUnknown {
+			codes[slot.Code]++
+			if ret.Code == CodeUnknown || codes[slot.Code] > codes[ret.Code] {
+				ret.Code = slot.Code
+			}
+		}
+
+		if slot.PrecipM != nil {
+			d := time.Hour
+			if i+1 < len(slots) {
+				d = slots[i+1].Time.Sub(slot.Time)
+			} else if i > 0 {
+				d = slot.Time.Sub(slots[i-1].Time)
+			}
+			sum := float32(d.Hours()) * *slot.PrecipM
+			if ret.PrecipSumM != nil {
+				sum += *ret.PrecipSumM
+			}
+			ret.PrecipSumM = &sum
+		}
+	}
+	return ret
+}
+
+// Merge returns s with all unknown fields taken from fallback. Backends set
+// the summary from daily provider data and wego completes it with Summarize.
+func (s Summary) Merge(fallback Summary) Summary {
+	if s.MinTempC == nil {
+		s.MinTempC = fallback.MinTempC
+	}
+	if s.MaxTempC == nil {
+		s.MaxTempC = fallback.MaxTempC
+	}
+	if s.MinFeelsLikeC == nil {
+		s.MinFeelsLikeC = fallback.MinFeelsLikeC
+	}
+	if s.MaxFeelsLikeC == nil {
+		s.MaxFeelsLikeC = fallback.MaxFeelsLikeC
+	}
+	if s.PrecipSumM == nil {
+		s.PrecipSumM = fallback.PrecipSumM
+	}
+	if s.MaxWindGustKmph == nil {
+		s.MaxWindGustKmph = fallback.MaxWindGustKmph
+	}
+	if s.MaxChanceOfRainPercent == nil {
+		s.MaxChanceOfRainPercent = fallback.MaxChanceOfRainPercent
+	}
+	if s.Code == CodeUnknown {
+		s.Code = fallback.Code
+	}
+	return s
+}
@@ -0,0 +1,144 @@
+package iface
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// TimeZone is the time zone of a location. It is serialized as its IANA name
+// like "Europe/Berlin". A nil Location means the time zone is unknown.
+type TimeZone struct {
+	*time.Location
+}
+
+var (
+	etcZoneRegexp   = regexp.MustCompile(`^Etc/GMT([+-][0-9]{1,2})$`)
+	fixedZoneRegexp = regexp.MustCompile(`^UTC([+-])([0-9]{2}):([0-9]{2})$`)
+)
+
+// LoadTimeZone returns the time zone with the IANA name name. The fixed zones
+// "Etc/GMT-2" and "UTC+05:30" returned by FixedTimeZone are understood even
+// if the time zone database is not available.
+func LoadTimeZone(name string) (TimeZone, error) {
+	if loc, err := time.LoadLocation(name); err == nil {
+		return TimeZone{loc}, nil
+	}
+	if m := etcZoneRegexp.FindStringSubmatch(name); m != nil {
+		h, _ := strconv.Atoi(m[1])
+		// the sign of the Etc zones is inverted
+		return TimeZone{time.FixedZone(name, -h*3600)}, nil
+	}
+	if m := fixedZoneRegexp.FindStringSubmatch(name); m != nil {
+		h, _ := strconv.Atoi(m[2])
+		min, _ := strconv.Atoi(m[3])
+		offset := h*3600 + min*60
+		if m[1] == "-" {
+			offset = -offset
+		}
+		return TimeZone{time.FixedZone(name, offset)}, nil
+	}
+	return TimeZone{}, fmt.Errorf("unknown time zone %q", name)
+}
+
+// FixedTimeZone returns a time zone without daylight saving time which is
+// offset seconds east of UTC. Whole hour offsets use the matching Etc zone.
+func FixedTimeZone(offset int) TimeZone {
+	if offset%3600 == 0 && offset >= -12*3600 && offset <= 14*3600 {
+		z, _ := LoadTimeZone(fmt.Sprintf("Etc/GMT%+d", -offset/3600))
+		return z
+	}
+	sign, abs := '+', offset
+	if offset < 0 {
+		sign, abs = '-', -offset
+	}
+	return TimeZone{time.FixedZone(fmt.Sprintf("UTC%c%02d:%02d", sign, abs/3600, abs%3600/60), offset)}
+}
+
+// LongitudeTimeZone returns the nautical time zone of the longitude lon, which
+// is the best guess if nothing else is known about a location.
+func LongitudeTimeZone(lon float32) TimeZone {
+	return FixedTimeZone(int(math.Round(float64(lon)/15)) * 3600)
+}
+
+// In returns t in the time zone z or t unchanged if z is unknown.
+func (z TimeZone) In(t time.Time) time.Time {
+	if z.Location == nil {
+		return t
+	}
+	return t.In(z.Location)
+}
+
+func (z TimeZone) MarshalJSON() ([]byte, error) {
+	if z.Location == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(z.Location.String())
+}
+
+func (z *TimeZone) UnmarshalJSON(b []byte) error {
+	var name *string
+	if err := json.Unmarshal(b, &name); err != nil {
+		return err
+	}
+	if name == nil || *name == "" {
+		z.Location = nil
+		return nil
+	}
+	tz, err := LoadTimeZone(*name)
+	if err != nil {
+		return err
+	}
+	*z = tz
+	return nil
+}
+
+// Local returns a copy of d with all times converted to d.TimeZone. Frontends
+// use it to show and group times in the local time of the location. If the
+// time zone is unknown, d is returned unchanged.
+func (d Data) Local() Data {
+	z := d.TimeZone
+	if z.Location == nil {
+		return d
+	}
+	conds := func(cs []Cond) []Cond {
+		if cs == nil {
+			return nil
+		}
+		ret := make([]Cond, len(cs))
+		for i, c := range cs {
+			c.Time = z.In(c.Time)
+			ret[i] = c
+		}
+		return ret
+	}
+
+	d.Current.Time = z.In(d.Current.Time)
+	d.Nowcast = conds(d.Nowcast)
+	if d.Alerts != nil {
+		alerts := make([]Alert, len(d.Alerts))
+		for i, a := range d.Alerts {
+			a.Onset, a.Expiry = z.In(a.Onset), z.In(a.Expiry)
+			alerts[i] = a
+		}
+		d.Alerts = alerts
+	}
+	if d.Forecast != nil {
+		days := make([]Day, len(d.Forecast))
+		for i, day := range d.Forecast {
+			day.Date = z.In(day.Date)
+			day.Slots = conds(day.Slots)
+			a := &day.Astronomy
+			for _, t := range []*time.Time{&a.Moonrise, &a.Moonset, &a.Sunrise, &a.Sunset, &a.SolarNoon,
+				&a.CivilDawn, &a.CivilDusk, &a.NauticalDawn, &a.NauticalDusk, &a.AstronomicalDawn, &a.AstronomicalDusk} {
+				*t = z.In(*t)
+			}
+			days[i] = day
+		}
+		d.Forecast = days
+	}
+	return d
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -8,11 +10,15 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	// time zones of the locations are loaded by their IANA names
+	_ "time/tzdata"
 
 	"github.com/schachmat/ingo"
-	_ "github.com/schachmat/wego/backends"
-	_ "github.com/schachmat/wego/frontends"
+	"github.com/schachmat/wego/cache"
+	"github.com/schachmat/wego/geocode"
+	"github.com/schachmat/wego/httpclient"
 	"github.com/schachmat/wego/iface"
+	"github.com/schachmat/wego/wego"
 )
 
 func pluginLists() {
@@ -32,6 +38,74 @@ func pluginLists() {
 	fmt.Fprintln(os.Stderr, "Available frontends:", strings.Join(fEnds, ", "))
 }
 
+// exitCodes maps the error classes returned by backends to the exit code and a
+// hint printed for them.
+var exitCodes = []struct {
+	err  error
+	code int
+	hint string
+}{
+	{iface.ErrUnknownLocation, 3, "Could not find the requested location."},
+	{iface.ErrAuth, 4, "Authentication failed, please check the api key of the backend."},
+	{iface.ErrQuota, 5, "The quota of the backend is exhausted, please try again later."},
+	{iface.ErrUpstream, 6, "The backend could not deliver weather data."},
+	{iface.ErrParse, 7, "The response of the backend could not be understood."},
+}
+
+// exitStale is the exit code if old cached data was shown, because the backend
+// could not be reached.
+const exitStale = 8
+
+// exitCode returns the exit code and hint matching the error class of err.
+func exitCode(err error) (int, string) {
+	for _, e := range exitCodes {
+		if errors.Is(err, e.err) {
+			return e.code, e.hint
+		}
+	}
+	return 1, ""
+}
+
+// fail prints err and exits with the code matching its error class.
+func fail(err error) {
+	code, hint := exitCode(err)
+	if hint != "" {
+		fmt.Fprintln(os.Stderr, hint)
+	}
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(code)
+}
+
+// parsed reports whether the command line is parsed already. Tests replace it
+// to set values as if they came from the config file.
+var parsed = flag.Parsed
+
+// locationList is the value of the -location flag. The flag can be repeated on
+// the command line or hold several locations separated by ";" in the config
+// file. Locations given on the command line replace the ones of the config
+// file.
+type locationList struct {
+	locs    []string
+	fromCLI bool
+}
+
+func (l *locationList) String() string {
+	return strings.Join(l.locs, ";")
+}
+
+func (l *locationList) Set(s string) error {
+	// ingo sets the values of the config file before the command line is parsed
+	if !parsed() || !l.fromCLI {
+		l.locs, l.fromCLI = nil, parsed()
+	}
+	for _, loc := range strings.Split(s, ";") {
+		if loc = strings.TrimSpace(loc); loc != "" {
+			l.locs = append(l.locs, loc)
+		}
+	}
+	return nil
+}
+
 func main() {
 	// initialize backends and frontends (flags and default config)
 	for _, be := range iface.AllBackends {
@@ -42,8 +116,12 @@ func main() {
 	}
 
 	// initialize global flags and default config
-	location := flag.String("location", "40.748,-73.985", "`LOCATION` to be queried")
-	flag.StringVar(location, "l", "40.748,-73.985", "`LOCATION` to be queried (shorthand)")
+	locations := &locationList{locs: []string{"40.748,-73.985"}}
+	flag.Var(locations, "locations", "`LOCATIONS` to be queried, separated by ;")
+	flag.Var(locations, "location", "`LOCATION` to be queried, repeat the flag to query several ones")
+	flag.Var(locations, "l", "`LOCATION` to be queried, repeat the flag to query several ones (shorthand)")
+	parallel := flag.Int("parallel", 4, "`NUMBER` of locations fetched at once")
+	overview := flag.Bool("overview", false, "Show one line per location instead of the full forecast of each one")
 	numdays := flag.Int("days", 3, "`NUMBER` of days of weather forecast to be displayed")
 	flag.IntVar(numdays, "d", 3, "`NUMBER` of days of weather forecast to be displayed (shorthand)")
 	unitSystem := flag.String("units", "metric", "`UNITSYSTEM` to use for output.\n    \tChoices are: metric, imperial, si, metric-ms")
@@ -52,6 +130,19 @@ func main() {
 	flag.StringVar(selectedBackend, "b", "openweathermap", "`BACKEND` to be used (shorthand)")
 	selectedFrontend := flag.String("frontend", "ascii-art-table", "`FRONTEND` to be used")
 	flag.StringVar(selectedFrontend, "f", "ascii-art-table", "`FRONTEND` to be used (shorthand)")
+	selectedGeocoder := flag.String("geocoder", "offline", "`GEOCODER` used to find place names for backends which only support coordinates.\n    \tChoices are: offline, openmeteo")
+	gazetteer := flag.String("gazetteer", "", "GeoNames cities `FILE` used by the offline geocoder instead of the builtin one")
+	cacheTTL := flag.Duration("cache-ttl", cache.DefaultTTL, "`DURATION` for which fetched weather data is reused instead of querying the backend again")
+	noCache := flag.Bool("no-cache", false, "Always query the backend and do not store the fetched weather data")
+	httpTimeout := flag.Duration("http-timeout", httpclient.DefaultTimeout, "`DURATION` after which a request to the backend is given up")
+	httpRetries := flag.Int("http-retries", httpclient.DefaultRetries, "`NUMBER` of retries if the backend is overloaded or asks to slow down")
+	httpsPolicy := flag.String("https-only", "upgrade", "`POLICY` for backends requested with plain http.\n    \tChoices are: upgrade, refuse, off")
+	proxy := flag.String("proxy", "", "http://, https:// or socks5:// `URL` of the proxy used for all requests instead of the HTTP_PROXY environment variable")
+	caFile := flag.String("ca-file", "", "`FILE` with PEM encoded CA certificates trusted in addition to the system ones")
+	locationPrecision := flag.String("location-precision", "exact", "`PRECISION` of the coordinates sent to the backend, e.g. 1km or 10km.\n    \tThe coordinates are snapped to a grid of that size to hide the exact location")
+	record := flag.String("record", "", "`DIR` to save the raw HTTP exchanges with the backend in, e.g. for bug reports or test fixtures.\n    \tThe cache is not used while recording")
+	offline := flag.Bool("offline", false, "Do not query the backend and show the last cached weather data instead")
+	compare := flag.String("compare", "", "Comma separated `BACKENDS` to query at once and show next to each other.\n    \tShortcut for -b ensemble -ensemble-members BACKENDS -f compare")
 
 	// print out a list of all backends and frontends in the usage
 	tmpUsage := flag.Usage
@@ -65,12 +156,34 @@ func main() {
 		log.Fatalf("Error parsing config: %v", err)
 	}
 
+	// wego cache clear removes all cached weather data
+	if flag.NArg() > 0 && flag.Arg(0) == "cache" {
+		if flag.NArg() != 2 || flag.Arg(1) != "clear" {
+			log.Fatal("Usage: wego cache clear")
+		}
+		dir, err := cache.Dir()
+		if err != nil {
+			log.Fatalf("Could not find cache directory: %v", err)
+		}
+		if err := cache.Clear(dir); err != nil {
+			log.Fatalf("Could not clear cache: %v", err)
+		}
+		return
+	}
+
 	// non-flag shortcut arguments overwrite possible flag arguments
 	for _, arg := range flag.Args() {
 		if v, err := strconv.Atoi(arg); err == nil && len(arg) == 1 {
 			*numdays = v
 		} else {
-			*location = arg
+			locations.locs = []string{arg}
+		}
+	}
+
+	if *compare != "" {
+		*selectedBackend, *selectedFrontend = "ensemble", "compare"
+		if err := flag.Set("ensemble-members", *compare); err != nil {
+			log.Fatal(err)
 		}
 	}
 
@@ -79,16 +192,97 @@ func main() {
 	if !ok {
 		log.Fatalf("Could not find selected backend \"%s\"", *selectedBackend)
 	}
-	r := be.Fetch(*location, *numdays)
+	if *offline && (*noCache || *record != "") {
+		log.Fatal("The -offline flag cannot be combined with -no-cache or -record")
+	}
+	policy, err := httpclient.ParsePolicy(*httpsPolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	transport, err := httpclient.NewTransport(*proxy, *caFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	httpOpts := httpclient.Options{Timeout: *httpTimeout, Retries: *httpRetries, HTTPS: policy, Transport: transport}
+	if *record != "" {
+		httpOpts.Transport = &httpclient.Recorder{Dir: *record, Base: transport}
+	} else if dir, err := cache.Dir(); err == nil && !*noCache && *selectedBackend != "json" {
+		// the json backend reads local files, caching them would only hide
+		// changes
+		httpOpts.Transport = &cache.Transport{Dir: dir, Base: transport}
+		be = cache.Options{Dir: dir, TTL: *cacheTTL, Offline: *offline}.New(*selectedBackend, be)
+	}
+	locs := make([]wego.Location, len(locations.locs))
+	for i, l := range locations.locs {
+		if locs[i], err = wego.ParseLocation(l); err != nil {
+			fail(err)
+		}
+	}
+	if len(locs) == 0 {
+		log.Fatal("No location given")
+	}
+	client := wego.NewClient(be)
+	client.SetHTTPClient(httpOpts.New())
+	precision, err := wego.ParsePrecision(*locationPrecision)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client.SetLocationPrecision(precision)
+	if *gazetteer != "" {
+		g, err := geocode.LoadGazetteer(*gazetteer)
+		if err != nil {
+			log.Fatalf("Could not load gazetteer: %v", err)
+		}
+		client.SetGeocoder(g)
+		client.SetReverseGeocoder(g)
+	}
+	switch *selectedGeocoder {
+	case "offline":
+	case "openmeteo":
+		// offline the names are resolved with the gazetteer instead, so only
+		// the cache is consulted
+		if !*offline {
+			client.SetGeocoder(geocode.OpenMeteo{})
+		}
+	default:
+		log.Fatalf("Could not find selected geocoder \"%s\"", *selectedGeocoder)
+	}
+	data, errs := client.FetchAll(context.Background(), locs, *numdays, *parallel)
+	if len(locs) == 1 && errs[0] != nil {
+		fail(errs[0])
+	}
+
+	// show the locations which could be fetched and exit with the code of the
+	// first which could not
+	code := 0
+	var fetched []iface.Data
+	for i, err := range errs {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not fetch the weather for %s: %v\n", locations.locs[i], err)
+			if code == 0 {
+				code, _ = exitCode(err)
+			}
+			continue
+		}
+		fetched = append(fetched, data[i])
+	}
+	for _, r := range fetched {
+		if r.Stale && code == 0 {
+			code = exitStale
+		}
+	}
 
 	// set unit system
-	unit := iface.UnitsMetric
-	if *unitSystem == "imperial" {
-		unit = iface.UnitsImperial
-	} else if *unitSystem == "si" {
-		unit = iface.UnitsSi
-	} else if *unitSystem == "metric-ms" {
-		unit = iface.UnitsMetricMs
+	unit, err := wego.ParseUnitSystem(*unitSystem)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *overview {
+		if err := wego.RenderOverview(os.Stdout, fetched, unit); err != nil {
+			log.Fatalf("Error rendering weather data: %v", err)
+		}
+		os.Exit(code)
 	}
 
 	// get selected frontend and render the weather data with it
@@ -96,5 +290,13 @@ func main() {
 	if !ok {
 		log.Fatalf("Could not find selected frontend \"%s\"", *selectedFrontend)
 	}
-	fe.Render(r, unit)
+	for i, r := range fetched {
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := wego.Render(os.Stdout, fe, r, unit); err != nil {
+			log.Fatalf("Error rendering weather data: %v", err)
+		}
+	}
+	os.Exit(code)
 }
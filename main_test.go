@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLocationListSet(t *testing.T) {
+	tests := []struct {
+		name   string
+		config []string
+		cli    []string
+		want   string
+	}{
+		{"default", nil, nil, "default"},
+		{"config", []string{"Berlin"}, nil, "Berlin"},
+		{"config list", []string{" Berlin ; Paris, FR;;"}, nil, "Berlin;Paris, FR"},
+		{"command line", nil, []string{"Berlin"}, "Berlin"},
+		{"command line replaces config", []string{"Berlin;Paris"}, []string{"Rome"}, "Rome"},
+		{"command line repeated", []string{"Berlin"}, []string{"Rome", "Oslo;Bern"}, "Rome;Oslo;Bern"},
+	}
+
+	defer func(orig func() bool) { parsed = orig }(parsed)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := &locationList{locs: []string{"default"}}
+			parsed = func() bool { return false }
+			for _, s := range test.config {
+				l.Set(s)
+			}
+			parsed = func() bool { return true }
+			for _, s := range test.cli {
+				l.Set(s)
+			}
+			if got := strings.Join(l.locs, ";"); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
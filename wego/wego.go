@@ -0,0 +1,341 @@
+// Package wego fetches weather forecasts from one of the wego backends and
+// renders them with one of the wego frontends. It can be used without the wego
+// command and does not touch the global flag set:
+//
+//	loc, err := wego.ParseLocation("59.329,18.068")
+//	if err != nil {
+//		return err
+//	}
+//	c := wego.New(wego.OpenMeteoOptions{})
+//	data, err := c.Fetch(ctx, loc, 3)
+//	if err != nil {
+//		return err
+//	}
+//	return wego.Render(os.Stdout, wego.AsciiArtTableOptions{}.New(), data, wego.UnitsMetric)
+package wego
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-colorable"
+	"github.com/mattn/go-isatty"
+	"github.com/schachmat/wego/astronomy"
+	"github.com/schachmat/wego/backends"
+	"github.com/schachmat/wego/frontends"
+	"github.com/schachmat/wego/geocode"
+	"github.com/schachmat/wego/httpclient"
+	"github.com/schachmat/wego/iface"
+)
+
+// Options of the builtin backends.
+type (
+	CaiyunOptions             = backends.CaiyunOptions
+	EnsembleOptions           = backends.EnsembleOptions
+	FallbackOptions           = backends.FallbackOptions
+	JSONBackendOptions        = backends.JSONOptions
+	OpenMeteoOptions          = backends.OpenMeteoOptions
+	OpenWeatherMapOptions     = backends.OpenWeatherMapOptions
+	SMHIOptions               = backends.SMHIOptions
+	WorldWeatherOnlineOptions = backends.WorldWeatherOnlineOptions
+)
+
+// Options of the builtin frontends.
+type (
+	AsciiArtTableOptions = frontends.AsciiArtTableOptions
+	CompareOptions       = frontends.CompareOptions
+	EmojiOptions         = frontends.EmojiOptions
+	JSONFrontendOptions  = frontends.JSONOptions
+	MarkdownOptions      = frontends.MarkdownOptions
+)
+
+type (
+	Data       = iface.Data
+	UnitSystem = iface.UnitSystem
+)
+
+const (
+	UnitsMetric   = iface.UnitsMetric
+	UnitsImperial = iface.UnitsImperial
+	UnitsSi       = iface.UnitsSi
+	UnitsMetricMs = iface.UnitsMetricMs
+)
+
+// Errors returned by Client.Fetch. Use errors.Is to check for them.
+var (
+	ErrUnknownLocation = iface.ErrUnknownLocation
+	ErrAuth            = iface.ErrAuth
+	ErrQuota           = iface.ErrQuota
+	ErrUpstream        = iface.ErrUpstream
+	ErrParse           = iface.ErrParse
+)
+
+// BackendOptions is implemented by the options of all builtin backends.
+type BackendOptions interface {
+	New() iface.Backend
+}
+
+// Location is the location to fetch the weather for. Which kinds of locations
+// are understood depends on the backend.
+type Location = iface.Location
+
+// ParseLocation parses a location like "59.329,18.068", "New York",
+// "10001,US" or "KJFK".
+func ParseLocation(s string) (Location, error) {
+	return iface.ParseLocation(s)
+}
+
+// Client fetches weather data from a single backend.
+type Client struct {
+	backend  iface.Backend
+	geocoder geocode.Geocoder
+	reverse  geocode.ReverseGeocoder
+	http     *httpclient.Client
+
+	precisionKm float64
+}
+
+// New returns a Client using the builtin backend configured by opts.
+func New(opts BackendOptions) *Client {
+	return NewClient(opts.New())
+}
+
+// NewClient returns a Client using backend, which can be one of the builtin
+// backends or a custom implementation. Place names are resolved with the
+// builtin gazetteer for backends which only support coordinates and unnamed
+// places are named after the closest city in it.
+func NewClient(backend iface.Backend) *Client {
+	return &Client{backend: backend, geocoder: geocode.Builtin(), reverse: geocode.Builtin()}
+}
+
+// SetGeocoder sets the Geocoder used to resolve place names for backends which
+// only support coordinates. A nil Geocoder disables the resolution.
+func (c *Client) SetGeocoder(g geocode.Geocoder) {
+	c.geocoder = g
+}
+
+// SetReverseGeocoder sets the ReverseGeocoder used to name the location if
+// the backend did not. A nil ReverseGeocoder leaves the location unnamed.
+func (c *Client) SetReverseGeocoder(r geocode.ReverseGeocoder) {
+	c.reverse = r
+}
+
+// SetHTTPClient sets the client used for all requests of the backend and the
+// geocoders. By default httpclient.Default is used. Pass a client with a custom
+// Transport to record, replay or fake the responses.
+func (c *Client) SetHTTPClient(h *httpclient.Client) {
+	c.http = h
+}
+
+// SetLocationPrecision makes the Client snap coordinates to a grid with cells
+// of about km × km before they are sent to the backend. Zero sends the exact
+// coordinates.
+func (c *Client) SetLocationPrecision(km float64) {
+	c.precisionKm = km
+}
+
+// name sets data.Location to the place closest to the queried coordinates or
+// the coordinates reported by the backend, e.g. "Stockholm, SE (3 km)".
+func (c *Client) name(ctx context.Context, loc Location, data *iface.Data) {
+	if c.reverse == nil || data.Location != "" {
+		return
+	}
+	point := data.GeoLoc
+	if loc.Kind == iface.LocationCoords {
+		point = &loc.LatLon
+	}
+	if point == nil {
+		return
+	}
+	place, km, err := c.reverse.ReverseGeocode(ctx, *point)
+	if err != nil {
+		return
+	}
+	data.Location = place.Name
+	if place.Country != "" {
+		data.Location += ", " + place.Country
+	}
+	if km >= 1 {
+		data.Location += fmt.Sprintf(" (%.0f km)", km)
+	}
+}
+
+// timeZoner is implemented by reverse geocoders which know time zones.
+type timeZoner interface {
+	TimeZone(loc iface.LatLon) iface.TimeZone
+}
+
+// timeZone returns the time zone at loc according to the reverse geocoder if
+// it knows time zones or the builtin gazetteer otherwise.
+func (c *Client) timeZone(loc iface.LatLon) iface.TimeZone {
+	if tz, ok := c.reverse.(timeZoner); ok {
+		return tz.TimeZone(loc)
+	}
+	return geocode.TimeZoneAt(loc)
+}
+
+// resolve turns loc into a location the backend supports. Postal and airport
+// codes the backend does not support are looked up as place names and place
+// names are turned into coordinates if the backend only supports coordinates.
+func (c *Client) resolve(ctx context.Context, loc Location) (Location, error) {
+	supported := c.backend.SupportedLocations()
+	geocodes := c.geocoder != nil && supported&iface.LocationName == 0 && supported&iface.LocationCoords != 0
+	if geocodes {
+		loc = loc.For(supported | iface.LocationName)
+	} else {
+		loc = loc.For(supported)
+	}
+	if !geocodes || loc.Kind != iface.LocationName {
+		return loc, nil
+	}
+	place, err := c.geocoder.Geocode(ctx, loc.Name)
+	if err != nil {
+		return loc, err
+	}
+	return Location{Kind: iface.LocationCoords, Raw: loc.Raw, LatLon: place.LatLon}, nil
+}
+
+// Fetch returns the current weather and a forecast for days days at loc.
+// Coordinates are snapped to the grid set with SetLocationPrecision first. Sun
+// events missing in the backend data are computed locally if the backend
+// reported the coordinates of the location. If the backend did not name the
+// location, it is named after the closest known place. The time zone is looked
+// up the same way if the backend did not report it and the forecast is grouped
+// into the days of that time zone. Day summaries missing in the backend data
+// are computed from the slots of the day. Asking for less than one day is an
+// error.
+func (c *Client) Fetch(ctx context.Context, loc Location, days int) (iface.Data, error) {
+	if days < 1 {
+		return iface.Data{}, fmt.Errorf("invalid number of days %d, use at least 1", days)
+	}
+	if c.http != nil {
+		ctx = httpclient.NewContext(ctx, c.http)
+	}
+	loc, err := c.resolve(ctx, loc)
+	if err != nil {
+		return iface.Data{}, err
+	}
+	query := loc
+	snapped := c.precisionKm > 0 && loc.Kind == iface.LocationCoords
+	if snapped {
+		query.LatLon = loc.LatLon.Snap(c.precisionKm)
+		query.Raw = query.String()
+	}
+	data, err := c.backend.Fetch(ctx, query, days)
+	if err != nil {
+		return data, err
+	}
+	if snapped {
+		data.PrecisionKm = c.precisionKm
+	}
+	// the exact coordinates never leave the machine, so the place is named
+	// after them instead of the snapped ones
+	c.name(ctx, loc, &data)
+	if data.TimeZone.Location == nil && data.GeoLoc != nil {
+		data.TimeZone = c.timeZone(*data.GeoLoc)
+		data = data.Regroup(days)
+	}
+	if data.GeoLoc != nil {
+		for i := range data.Forecast {
+			astronomy.Fill(&data.Forecast[i].Astronomy, data.TimeZone.In(data.Forecast[i].Date), *data.GeoLoc)
+		}
+	}
+	for i, day := range data.Forecast {
+		data.Forecast[i].Summary = day.Summary.Merge(iface.Summarize(day.Slots))
+	}
+	return data, nil
+}
+
+// FetchAll fetches the weather at all locs like Fetch, with at most workers
+// requests at once. The data and errors are returned in the order of locs.
+// Every location has either data or an error.
+func (c *Client) FetchAll(ctx context.Context, locs []Location, days, workers int) ([]iface.Data, []error) {
+	data := make([]iface.Data, len(locs))
+	errs := make([]error, len(locs))
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(locs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				data[i], errs[i] = c.Fetch(ctx, locs[i], days)
+			}
+		}()
+	}
+	for i := range locs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return data, errs
+}
+
+// terminal returns w with color escape sequences filtered out unless w is a
+// terminal.
+func terminal(w io.Writer) io.Writer {
+	if f, ok := w.(*os.File); ok && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())) {
+		return colorable.NewColorable(f)
+	}
+	return colorable.NewNonColorable(w)
+}
+
+// Render writes data to w using the frontend fe. Color escape sequences are
+// only written if w is a terminal.
+func Render(w io.Writer, fe iface.Frontend, data iface.Data, units iface.UnitSystem) error {
+	return fe.Render(terminal(w), data, units)
+}
+
+// RenderOverview writes one line per element of data to w, showing the current
+// weather and today's temperatures and chance of rain. Color escape sequences
+// are only written if w is a terminal.
+func RenderOverview(w io.Writer, data []iface.Data, units iface.UnitSystem) error {
+	return frontends.Overview(terminal(w), data, units)
+}
+
+// ParsePrecision parses a location precision like "1km" or "500m" and returns
+// it in km. A number without unit is taken as km. "exact" and "0" return
+// zero.
+func ParsePrecision(s string) (float64, error) {
+	unit := 1.0
+	num := s
+	switch {
+	case s == "exact":
+		return 0, nil
+	case strings.HasSuffix(s, "km"):
+		num = strings.TrimSuffix(s, "km")
+	case strings.HasSuffix(s, "m"):
+		num, unit = strings.TrimSuffix(s, "m"), 0.001
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, fmt.Errorf("invalid location precision %q, use e.g. exact, 500m or 10km", s)
+	}
+	return v * unit, nil
+}
+
+// ParseUnitSystem returns the unit system called name. Valid names are metric,
+// imperial, si and metric-ms.
+func ParseUnitSystem(name string) (iface.UnitSystem, error) {
+	switch name {
+	case "metric":
+		return iface.UnitsMetric, nil
+	case "imperial":
+		return iface.UnitsImperial, nil
+	case "si":
+		return iface.UnitsSi, nil
+	case "metric-ms":
+		return iface.UnitsMetricMs, nil
+	}
+	return iface.UnitsMetric, fmt.Errorf("unknown unit system %q", name)
+}
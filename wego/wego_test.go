@@ -0,0 +1,134 @@
+package wego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/geocode"
+	"github.com/schachmat/wego/iface"
+)
+
+// fakeBackend names the data after the location and fails for the locations
+// in fail. The first locations answer last so the fetches finish out of order.
+// It records how many fetches ran at once and the last queried location.
+type fakeBackend struct {
+	fail map[string]bool
+
+	mu      sync.Mutex
+	running int
+	peak    int
+	last    iface.Location
+}
+
+func (f *fakeBackend) Setup() {}
+
+func (f *fakeBackend) SupportedLocations() iface.LocationKind {
+	return iface.LocationAny
+}
+
+func (f *fakeBackend) Fetch(ctx context.Context, loc iface.Location, numdays int) (iface.Data, error) {
+	f.mu.Lock()
+	f.running++
+	f.last = loc
+	if f.running > f.peak {
+		f.peak = f.running
+	}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.running--
+		f.mu.Unlock()
+	}()
+
+	var i int
+	fmt.Sscanf(loc.Name, "City %d", &i)
+	time.Sleep(time.Duration(10-i) * time.Millisecond)
+	if f.fail[loc.Name] {
+		return iface.Data{}, fmt.Errorf("%s: %w", loc.Name, iface.ErrUnknownLocation)
+	}
+	return iface.Data{Location: loc.Name}, nil
+}
+
+func TestFetchAll(t *testing.T) {
+	var locs []Location
+	for i := 0; i < 8; i++ {
+		loc, err := ParseLocation(fmt.Sprintf("City %d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		locs = append(locs, loc)
+	}
+
+	for _, workers := range []int{0, 1, 3, 20} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			be := &fakeBackend{fail: map[string]bool{"City 2": true, "City 5": true}}
+			c := NewClient(be)
+			c.SetReverseGeocoder(nil)
+
+			data, errs := c.FetchAll(context.Background(), locs, 1, workers)
+			if len(data) != len(locs) || len(errs) != len(locs) {
+				t.Fatalf("got %d data and %d errors for %d locations", len(data), len(errs), len(locs))
+			}
+			for i, loc := range locs {
+				if be.fail[loc.Name] {
+					if !errors.Is(errs[i], ErrUnknownLocation) {
+						t.Errorf("%s: got error %v, want ErrUnknownLocation", loc.Name, errs[i])
+					}
+					continue
+				}
+				if errs[i] != nil {
+					t.Errorf("%s: %v", loc.Name, errs[i])
+				}
+				if data[i].Location != loc.Name {
+					t.Errorf("data %d is of %q, want %q", i, data[i].Location, loc.Name)
+				}
+			}
+			limit := workers
+			if limit < 1 {
+				limit = 1
+			}
+			if be.peak > limit {
+				t.Errorf("%d fetches at once with %d workers", be.peak, workers)
+			}
+		})
+	}
+}
+
+// fakeReverse names every point "Here" and records the last one asked for.
+type fakeReverse struct {
+	last iface.LatLon
+}
+
+func (f *fakeReverse) ReverseGeocode(ctx context.Context, loc iface.LatLon) (geocode.Place, float64, error) {
+	f.last = loc
+	return geocode.Place{Name: "Here"}, 0, nil
+}
+
+func TestFetchSnapsOnlyTheQuery(t *testing.T) {
+	loc, err := ParseLocation("59.3293,18.0686")
+	if err != nil {
+		t.Fatal(err)
+	}
+	be, reverse := &fakeBackend{}, &fakeReverse{}
+	c := NewClient(be)
+	c.SetReverseGeocoder(reverse)
+	c.SetLocationPrecision(10)
+
+	data, err := c.Fetch(context.Background(), loc, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := loc.LatLon.Snap(10); be.last.LatLon != want {
+		t.Errorf("backend queried at %v, want the snapped %v", be.last.LatLon, want)
+	}
+	if reverse.last != loc.LatLon {
+		t.Errorf("named after %v, want the exact %v", reverse.last, loc.LatLon)
+	}
+	if data.Location != "Here" || data.PrecisionKm != 10 {
+		t.Errorf("got %q with precision %v km", data.Location, data.PrecisionKm)
+	}
+}